@@ -0,0 +1,273 @@
+// Package crawler keeps scraping a list of routes to refresh the trips and average prices the graph relies on. Jobs,
+// one per route and travel date, are spread over a pool of workers while each provider gets at most as many requests
+// at once and as often as its catalogue entry allows.
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jcasado94/connecc/providers"
+	"github.com/jcasado94/connecc/scraping"
+	"github.com/jcasado94/connecc/validation"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultWorkers = 4
+	// refreshAge is how long the trips found by a job are considered fresh, as the Gen relationships of the graph.
+	refreshAge  = time.Hour * 24
+	maxAttempts = 3
+)
+
+type providerLimit struct {
+	limiter *rate.Limiter
+	slots   chan struct{}
+}
+
+type Crawler struct {
+	scrapers  map[string]scraping.Scraper
+	limits    map[string]*providerLimit
+	store     JobStore
+	sink      Sink
+	validator *validation.Validator
+	deduper   *scraping.Deduper
+	workers   int
+	now       func() time.Time
+}
+
+// New creates a Crawler searching with scrapers, keyed by provider name, within the limits catalogue sets for each
+// provider. workers bounds the jobs run at once across all providers.
+func New(catalogue *providers.Catalogue, scrapers map[string]scraping.Scraper, store JobStore, sink Sink, workers int) (*Crawler, error) {
+	if workers < 1 {
+		workers = defaultWorkers
+	}
+	limits := make(map[string]*providerLimit)
+	for name := range scrapers {
+		p, err := catalogue.ByName(name)
+		if err != nil {
+			return nil, err
+		}
+		limit := rate.Inf
+		if p.Scraper.RequestInterval.Duration > 0 {
+			limit = rate.Every(p.Scraper.RequestInterval.Duration)
+		}
+		slots := p.Scraper.Workers
+		if slots < 1 {
+			slots = 1
+		}
+		limits[name] = &providerLimit{
+			limiter: rate.NewLimiter(limit, 1),
+			slots:   make(chan struct{}, slots),
+		}
+	}
+	return &Crawler{
+		scrapers: scrapers,
+		limits:   limits,
+		store:    store,
+		sink:     sink,
+		workers:  workers,
+		now:      time.Now,
+	}, nil
+}
+
+// SetValidator makes the crawler normalise the trips it finds and drop the invalid ones before ingesting them.
+func (c *Crawler) SetValidator(v *validation.Validator) {
+	c.validator = v
+}
+
+// SetDeduper makes the crawler merge the trips it finds into d, keeping the latest price of each of their fares and its
+// history. Sinks still only get the fares each scrape found, so that fares gone since aren't ingested again.
+func (c *Crawler) SetDeduper(d *scraping.Deduper) {
+	c.deduper = d
+}
+
+// Schedule adds a pending job for every route and day from today until its horizon, unless there's already one
+// pending, or done less than refreshAge ago.
+func (c *Crawler) Schedule(routes []Route) error {
+	jobs, err := c.store.Jobs()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*Job)
+	for _, j := range jobs {
+		existing[j.Id()] = j
+	}
+
+	now := c.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, r := range routes {
+		if _, ok := c.scrapers[r.Provider]; !ok {
+			return fmt.Errorf("No scraper for provider %s", r.Provider)
+		}
+		for d := 0; d <= r.Horizon; d++ {
+			j := newJob(r, today.AddDate(0, 0, d))
+			if old, ok := existing[j.Id()]; ok {
+				switch {
+				case old.State == Pending, old.State == Running:
+					continue
+				case old.State == Done && now.Sub(old.UpdatedAt) < refreshAge:
+					continue
+				}
+			}
+			j.UpdatedAt, j.ScheduledAt = now, now
+			err = c.store.Put(j)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run works through the pending jobs in the store, along with the ones a previous run left running and the failed
+// ones with attempts left. It returns once they're all over, or at the first error persisting their state.
+func (c *Crawler) Run(ctx context.Context) error {
+	jobs, err := c.runnableJobs()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ch := make(chan *Job)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for w := 0; w < c.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				err := c.process(ctx, j)
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+sending:
+	for _, j := range jobs {
+		select {
+		case ch <- j:
+		case <-ctx.Done():
+			break sending
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// Loop schedules the routes and runs the jobs every interval until ctx is done.
+func (c *Crawler) Loop(ctx context.Context, routes []Route, interval time.Duration) error {
+	for {
+		err := c.Schedule(routes)
+		if err != nil {
+			return err
+		}
+		err = c.Run(ctx)
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// runnableJobs returns the jobs to run sorted by travel date, expiring the ones whose date already went by.
+func (c *Crawler) runnableJobs() ([]*Job, error) {
+	jobs, err := c.store.Jobs()
+	if err != nil {
+		return nil, err
+	}
+	now := c.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if c.deduper != nil {
+		c.deduper.Prune(today)
+	}
+	runnable := make([]*Job, 0)
+	for _, j := range jobs {
+		switch {
+		case j.State == Done, j.State == Expired, j.State == Failed && j.Attempts >= maxAttempts:
+			continue
+		case j.Date.Before(today):
+			j.State, j.UpdatedAt = Expired, now
+			err = c.store.Put(j)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		runnable = append(runnable, j)
+	}
+	sort.Slice(runnable, func(i, k int) bool {
+		if !runnable[i].Date.Equal(runnable[k].Date) {
+			return runnable[i].Date.Before(runnable[k].Date)
+		}
+		return runnable[i].Id() < runnable[k].Id()
+	})
+	return runnable, nil
+}
+
+// process runs a job within the limits of its provider, ingesting the trips it finds once each. Scraping and ingestion
+// errors fail the job, while the ones persisting its state are returned.
+func (c *Crawler) process(ctx context.Context, j *Job) error {
+	limit := c.limits[j.Route.Provider]
+	select {
+	case limit.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil
+	}
+	defer func() { <-limit.slots }()
+	err := limit.limiter.Wait(ctx)
+	if err != nil {
+		return nil
+	}
+
+	j.State, j.Attempts, j.Error, j.UpdatedAt = Running, j.Attempts+1, "", c.now()
+	err = c.store.Put(j)
+	if err != nil {
+		return err
+	}
+
+	// A single adult, as the average prices are of.
+	trips, err := c.scrapers[j.Route.Provider].GetTrips(j.Route.Departure, j.Route.Arrival, j.Date.Day(), int(j.Date.Month()), j.Date.Year(), 1, 0, 0)
+	if err == nil {
+		if c.validator != nil {
+			var rejected []validation.Rejection
+			trips, rejected = c.validator.Check(trips)
+			for _, r := range rejected {
+				log.Printf("Crawler. Dropping invalid trip of job %s: %v", j.Id(), r.Violations)
+			}
+		}
+		if c.deduper != nil {
+			trips = c.deduper.Add(trips)
+		} else {
+			trips = scraping.DedupeTrips(trips)
+		}
+		err = c.sink.Ingest(j, trips)
+	}
+	if err != nil {
+		j.State, j.Error = Failed, err.Error()
+	} else {
+		j.State = Done
+	}
+	j.UpdatedAt = c.now()
+	return c.store.Put(j)
+}
@@ -0,0 +1,345 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+	"github.com/jcasado94/connecc/providers"
+	"github.com/jcasado94/connecc/scraping"
+	"github.com/jcasado94/connecc/validation"
+)
+
+var testNow = time.Date(2019, time.Month(9), 8, 10, 0, 0, 0, time.UTC)
+
+// fakeScraper returns a single trip priced after the travel day, failing on the days in fail.
+type fakeScraper struct {
+	mu                 sync.Mutex
+	calls, running, at int
+	fail               map[int]bool
+}
+
+func (sc *fakeScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*scraping.Trip, error) {
+	sc.mu.Lock()
+	sc.calls++
+	sc.running++
+	if sc.running > sc.at {
+		sc.at = sc.running
+	}
+	sc.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	defer func() {
+		sc.mu.Lock()
+		sc.running--
+		sc.mu.Unlock()
+	}()
+	if sc.fail[day] {
+		return nil, fmt.Errorf("No trips on %d", day)
+	}
+	price := money.New(int64(day*100), "USD")
+	return []*scraping.Trip{{Fares: []*scraping.Fare{{Type: "standard", Price: price, Total: price}}}}, nil
+}
+
+// fakeUpdater adds the prices of each update id once, as drivers.MongoDriver does.
+type fakeUpdater struct {
+	prices  map[[2]int][]float64
+	updated map[string]bool
+}
+
+func (u *fakeUpdater) UpdateAvgPrice(s, t int, price float64, travelDate, searchDate time.Time, updateId string) error {
+	if u.updated == nil {
+		u.updated = make(map[string]bool)
+	}
+	if u.updated[updateId] {
+		return nil
+	}
+	u.updated[updateId] = true
+	u.prices[[2]int{s, t}] = append(u.prices[[2]int{s, t}], price)
+	return nil
+}
+
+func newTestCrawler(t *testing.T, store JobStore, scrapers map[string]scraping.Scraper, updater *fakeUpdater) *Crawler {
+	catalogue, err := providers.Parse([]byte(`{
+		"spirit": {"id": 0, "mode": "air", "currency": "USD", "fareTypes": ["standard"]},
+		"megabus": {"id": 1, "mode": "bus", "currency": "USD", "fareTypes": ["standard"], "scraper": {"workers": 2}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(catalogue, scrapers, store, NewAveragePriceSink(updater, "USD"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.now = func() time.Time { return testNow }
+	return c
+}
+
+func TestRun(t *testing.T) {
+	spirit, megabus := &fakeScraper{}, &fakeScraper{}
+	updater := &fakeUpdater{prices: make(map[[2]int][]float64)}
+	store := NewMemoryJobStore()
+	c := newTestCrawler(t, store, map[string]scraping.Scraper{"spirit": spirit, "megabus": megabus}, updater)
+	routes := []Route{
+		{Provider: "spirit", Departure: "BOS", Arrival: "DEN", DepartureNode: 1, ArrivalNode: 2, Horizon: 2},
+		{Provider: "megabus", Departure: "123", Arrival: "289", DepartureNode: 3, ArrivalNode: 4, Horizon: 5},
+	}
+	err := c.Schedule(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spirit.calls != 3 || megabus.calls != 6 {
+		t.Errorf("Expected 3 Spirit and 6 Megabus searches, got %d and %d", spirit.calls, megabus.calls)
+	}
+	if spirit.at > 1 || megabus.at > 2 {
+		t.Errorf("Provider limits exceeded. Spirit ran %d searches at once and Megabus %d", spirit.at, megabus.at)
+	}
+	if len(updater.prices[[2]int{1, 2}]) != 3 || len(updater.prices[[2]int{3, 4}]) != 6 {
+		t.Errorf("Expected an average update per job, got %v", updater.prices)
+	}
+	jobs, _ := store.Jobs()
+	for _, j := range jobs {
+		if j.State != Done || j.Attempts != 1 {
+			t.Errorf("Expected job %s done at the first attempt, got %s after %d", j.Id(), j.State, j.Attempts)
+		}
+	}
+
+	// Jobs done today are fresh, so scheduling again adds nothing to run.
+	err = c.Schedule(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spirit.calls != 3 || megabus.calls != 6 {
+		t.Errorf("Expected no new searches, got %d and %d", spirit.calls, megabus.calls)
+	}
+}
+
+func TestRunRetriesAndResumes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crawler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jobs.json")
+
+	store, err := NewFileJobStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route := Route{Provider: "spirit", Departure: "BOS", Arrival: "DEN", DepartureNode: 1, ArrivalNode: 2, Horizon: 1}
+	// A job left running by a crashed crawler and one whose date already went by.
+	interrupted := newJob(route, time.Date(2019, time.Month(9), 9, 0, 0, 0, 0, time.UTC))
+	interrupted.State, interrupted.Attempts = Running, 1
+	old := newJob(route, time.Date(2019, time.Month(9), 1, 0, 0, 0, 0, time.UTC))
+	for _, j := range []*Job{interrupted, old} {
+		err = store.Put(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err = NewFileJobStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spirit := &fakeScraper{fail: map[int]bool{8: true}}
+	updater := &fakeUpdater{prices: make(map[[2]int][]float64)}
+	c := newTestCrawler(t, store, map[string]scraping.Scraper{"spirit": spirit}, updater)
+	err = c.Schedule([]Route{route})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxAttempts+1; i++ {
+		err = c.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err = NewFileJobStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, _ := store.Jobs()
+	expected := map[int]struct {
+		state    JobState
+		attempts int
+	}{
+		1: {Expired, 0},
+		8: {Failed, maxAttempts},
+		9: {Done, 2},
+	}
+	if len(jobs) != len(expected) {
+		t.Fatalf("Expected %d jobs, got %d", len(expected), len(jobs))
+	}
+	for _, j := range jobs {
+		want := expected[j.Date.Day()]
+		if j.State != want.state || j.Attempts != want.attempts {
+			t.Errorf("Job %s differs. Want %s after %d attempts, got %s after %d", j.Id(), want.state, want.attempts, j.State, j.Attempts)
+		}
+	}
+	if spirit.calls != maxAttempts+1 {
+		t.Errorf("Expected %d searches, got %d", maxAttempts+1, spirit.calls)
+	}
+}
+
+func TestRunValidates(t *testing.T) {
+	updater := &fakeUpdater{prices: make(map[[2]int][]float64)}
+	c := newTestCrawler(t, NewMemoryJobStore(), map[string]scraping.Scraper{"spirit": &fakeScraper{}}, updater)
+	c.SetValidator(&validation.Validator{})
+	err := c.Schedule([]Route{{Provider: "spirit", Departure: "BOS", Arrival: "DEN", DepartureNode: 1, ArrivalNode: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fakeScraper trips have no legs.
+	if len(updater.prices) != 0 {
+		t.Errorf("Expected no prices from invalid trips, got %v", updater.prices)
+	}
+}
+
+// faresScraper returns the same flight, listed twice under differently written ids, with the fare types of the next of
+// its scrapes each time.
+type faresScraper struct {
+	scrapes [][]string
+}
+
+func (sc *faresScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*scraping.Trip, error) {
+	fareTypes := sc.scrapes[0]
+	sc.scrapes = sc.scrapes[1:]
+	trips := []*scraping.Trip{
+		&scraping.Trip{Legs: []*scraping.Leg{testLeg("NK 123")}, ScrapedAt: testNow},
+		&scraping.Trip{Legs: []*scraping.Leg{testLeg("nk123")}, ScrapedAt: testNow},
+	}
+	for _, trip := range trips {
+		for _, ft := range fareTypes {
+			price := money.New(1000, "USD")
+			trip.Fares = append(trip.Fares, &scraping.Fare{Type: ft, Price: price, Total: price})
+		}
+	}
+	return trips, nil
+}
+
+func testLeg(id string) *scraping.Leg {
+	return &scraping.Leg{Dep: "BOS", Arr: "DEN", DepTime: testNow.Add(time.Hour), ArrTime: testNow.Add(time.Hour * 4), Id: id}
+}
+
+func TestRunDedupes(t *testing.T) {
+	updater := &fakeUpdater{prices: make(map[[2]int][]float64)}
+	recorder := &fakeRecorder{trips: make(map[[2]int]int), fares: make(map[[2]int]int)}
+	sc := &faresScraper{scrapes: [][]string{{"standard", "9Dollar"}, {"standard"}}}
+	c := newTestCrawler(t, NewMemoryJobStore(), map[string]scraping.Scraper{"spirit": sc}, updater)
+	c.sink = NewPriceHistorySink(recorder)
+	d := scraping.NewDeduper()
+	c.SetDeduper(d)
+	j := newJob(Route{Provider: "spirit", Departure: "BOS", Arrival: "DEN", DepartureNode: 1, ArrivalNode: 2}, testNow)
+	// The flight is merged and recorded once per scrape, and the 9Dollar fare sold out by the second one isn't recorded
+	// again.
+	for i, expected := range []int{2, 3} {
+		err := c.process(context.Background(), j)
+		if err != nil || j.State != Done {
+			t.Fatalf("Expected scrape %d done, got %v, %s", i, err, j.Error)
+		}
+		if n := recorder.trips[[2]int{1, 2}]; n != i+1 {
+			t.Errorf("Expected %d trips recorded after scrape %d, got %d", i+1, i, n)
+		}
+		if n := recorder.fares[[2]int{1, 2}]; n != expected {
+			t.Errorf("Expected %d fares recorded after scrape %d, got %d", expected, i, n)
+		}
+	}
+	trip := &scraping.Trip{Legs: []*scraping.Leg{testLeg("NK123")}}
+	if h := d.History(trip, "9Dollar"); len(h) != 1 {
+		t.Errorf("Expected the deduper to keep the 9Dollar price, got %v", h)
+	}
+}
+
+type fakeRecorder struct {
+	mu    sync.Mutex
+	trips map[[2]int]int
+	fares map[[2]int]int
+}
+
+func (r *fakeRecorder) RecordPrices(s, t int, date time.Time, trips []*scraping.Trip) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trips[[2]int{s, t}] += len(trips)
+	for _, trip := range trips {
+		r.fares[[2]int{s, t}] += len(trip.Fares)
+	}
+	return nil
+}
+
+// flakySink fails the first fails ingestions.
+type flakySink struct {
+	fails int
+}
+
+func (s *flakySink) Ingest(j *Job, trips []*scraping.Trip) error {
+	if s.fails > 0 {
+		s.fails--
+		return fmt.Errorf("no reachable servers")
+	}
+	return nil
+}
+
+func TestAveragePriceSinkRetries(t *testing.T) {
+	updater := &fakeUpdater{prices: make(map[[2]int][]float64)}
+	c := newTestCrawler(t, NewMemoryJobStore(), map[string]scraping.Scraper{"megabus": &fakeScraper{}}, updater)
+	c.sink = Sinks{c.sink, &flakySink{fails: 1}}
+	j := newJob(Route{Provider: "megabus", Departure: "123", Arrival: "289", DepartureNode: 3, ArrivalNode: 4}, testNow)
+	j.ScheduledAt = testNow
+	// The retry of the failed run doesn't add its price again, while the run refreshing the job later does.
+	for i, expected := range []struct {
+		state  JobState
+		prices int
+	}{{Failed, 1}, {Done, 1}, {Done, 2}} {
+		if i == 2 {
+			j.ScheduledAt = testNow.Add(refreshAge)
+		}
+		err := c.process(context.Background(), j)
+		if err != nil || j.State != expected.state {
+			t.Fatalf("Expected run %d %s, got %v, %s", i, expected.state, err, j.State)
+		}
+		if n := len(updater.prices[[2]int{3, 4}]); n != expected.prices {
+			t.Errorf("Expected %d prices after run %d, got %d", expected.prices, i, n)
+		}
+	}
+}
+
+func TestPriceHistorySink(t *testing.T) {
+	updater := &fakeUpdater{prices: make(map[[2]int][]float64)}
+	recorder := &fakeRecorder{trips: make(map[[2]int]int), fares: make(map[[2]int]int)}
+	c := newTestCrawler(t, NewMemoryJobStore(), map[string]scraping.Scraper{"megabus": &fakeScraper{}}, updater)
+	c.sink = Sinks{c.sink, NewPriceHistorySink(recorder)}
+	err := c.Schedule([]Route{{Provider: "megabus", Departure: "123", Arrival: "289", DepartureNode: 3, ArrivalNode: 4, Horizon: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := recorder.fares[[2]int{3, 4}]; n != 3 {
+		t.Errorf("Expected a fare recorded per day, got %d", n)
+	}
+	if n := len(updater.prices[[2]int{3, 4}]); n != 3 {
+		t.Errorf("Expected an average update per day, got %d", n)
+	}
+}
@@ -0,0 +1,158 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type JobState string
+
+const (
+	Pending JobState = "pending"
+	Running JobState = "running"
+	Done    JobState = "done"
+	Failed  JobState = "failed"
+	// Expired jobs were still pending when their travel date went by.
+	Expired JobState = "expired"
+)
+
+// Route is an origin and destination searched with a provider every day until Horizon days ahead. Departure and
+// Arrival are the stop ids the provider's scraper takes, while DepartureNode and ArrivalNode are the graph nodes the
+// average prices are kept for. Routes are always searched for a single adult, the party the average prices are of.
+type Route struct {
+	Provider      string `json:"provider"`
+	Departure     string `json:"departure"`
+	Arrival       string `json:"arrival"`
+	DepartureNode int    `json:"departureNode"`
+	ArrivalNode   int    `json:"arrivalNode"`
+	Horizon       int    `json:"horizon"`
+}
+
+// Job is the search of a route on a given date.
+type Job struct {
+	Route     Route     `json:"route"`
+	Date      time.Time `json:"date"`
+	State     JobState  `json:"state"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// ScheduledAt is when the job was last scheduled, which its retries share.
+	ScheduledAt time.Time `json:"scheduledAt"`
+}
+
+func newJob(r Route, date time.Time) *Job {
+	return &Job{
+		Route: r,
+		Date:  date,
+		State: Pending,
+	}
+}
+
+// Id identifies the job among the ones of every route and date.
+func (j *Job) Id() string {
+	return fmt.Sprintf("%s:%s-%s:%s", j.Route.Provider, j.Route.Departure, j.Route.Arrival, j.Date.Format("2006-01-02"))
+}
+
+// RunId identifies the job as last scheduled, so that sinks can tell the retries of a failed run, which share it, from
+// the runs refreshing the job later.
+func (j *Job) RunId() string {
+	return fmt.Sprintf("%s@%d", j.Id(), j.ScheduledAt.UnixNano())
+}
+
+// JobStore persists the state of the jobs, so that a crawler resumes its work after a restart.
+type JobStore interface {
+	Jobs() ([]*Job, error)
+	Put(j *Job) error
+}
+
+// MemoryJobStore keeps the jobs in memory only.
+type MemoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{
+		jobs: make(map[string]Job),
+	}
+}
+
+func (s *MemoryJobStore) Jobs() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j := j
+		jobs = append(jobs, &j)
+	}
+	return jobs, nil
+}
+
+func (s *MemoryJobStore) Put(j *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[j.Id()] = *j
+	return nil
+}
+
+// FileJobStore keeps the jobs in a JSON file, rewritten on every change.
+type FileJobStore struct {
+	MemoryJobStore
+	path string
+}
+
+// NewFileJobStore creates a FileJobStore over the file at path, loading the jobs it already holds.
+func NewFileJobStore(path string) (*FileJobStore, error) {
+	s := &FileJobStore{
+		MemoryJobStore: *NewMemoryJobStore(),
+		path:           path,
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	err = json.Unmarshal(data, &jobs)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed job store %s: %v", path, err)
+	}
+	for _, j := range jobs {
+		s.jobs[j.Id()] = j
+	}
+	return s, nil
+}
+
+func (s *FileJobStore) Put(j *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[j.Id()] = *j
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a crash never leaves a truncated store behind.
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jcasado94/connecc/scraping"
+)
+
+// Sink ingests the trips found by a job. A job whose ingestion failed is run again, so sinks may get the trips of a
+// run more than once.
+type Sink interface {
+	Ingest(j *Job, trips []*scraping.Trip) error
+}
+
+// Sinks ingests trips into every one of its sinks, in order, stopping at the first error.
+type Sinks []Sink
+
+func (ss Sinks) Ingest(j *Job, trips []*scraping.Trip) error {
+	for _, s := range ss {
+		err := s.Ingest(j, trips)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AverageUpdater adds a price to the average price of the trips between two graph nodes, travelling on travelDate and
+// searched on searchDate. Prices are only added once per updateId. drivers.MongoDriver is one.
+type AverageUpdater interface {
+	UpdateAvgPrice(s, t int, price float64, travelDate, searchDate time.Time, updateId string) error
+}
+
+// AveragePriceSink feeds the cheapest adult price of every job into the average price of its route, bucketed by the
+// travel date and the time the trip was scraped, or now if unknown. Prices are updated under the run id of the job, so
+// that the retries of a run don't add its price again.
+type AveragePriceSink struct {
+	updater  AverageUpdater
+	currency string
+	// mu serializes the updates, which read and write the average back.
+	mu sync.Mutex
+}
+
+// NewAveragePriceSink creates an AveragePriceSink keeping the averages in currency. Fares in other currencies are ignored.
+func NewAveragePriceSink(updater AverageUpdater, currency string) *AveragePriceSink {
+	return &AveragePriceSink{
+		updater:  updater,
+		currency: currency,
+	}
+}
+
+func (s *AveragePriceSink) Ingest(j *Job, trips []*scraping.Trip) error {
+	cheapest := -1.0
+	var searchDate time.Time
+	for _, t := range trips {
+		for _, f := range t.Fares {
+			if f.Price.Currency != s.currency {
+				continue
+			}
+			if price := f.Price.Float64(); cheapest < 0 || price < cheapest {
+				cheapest, searchDate = price, t.ScrapedAt
+			}
+		}
+	}
+	if cheapest < 0 {
+		return nil
+	}
+	if searchDate.IsZero() {
+		searchDate = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updater.UpdateAvgPrice(j.Route.DepartureNode, j.Route.ArrivalNode, cheapest, j.Date, searchDate, j.RunId())
+}
+
+// PriceRecorder keeps every fare of the trips found from a graph node to another travelling on some date.
+// drivers.MongoDriver is one.
+type PriceRecorder interface {
+	RecordPrices(s, t int, date time.Time, trips []*scraping.Trip) error
+}
+
+// PriceHistorySink records the fares of every job, building the price history of its route.
+type PriceHistorySink struct {
+	recorder PriceRecorder
+}
+
+func NewPriceHistorySink(recorder PriceRecorder) *PriceHistorySink {
+	return &PriceHistorySink{recorder}
+}
+
+func (s *PriceHistorySink) Ingest(j *Job, trips []*scraping.Trip) error {
+	if len(trips) == 0 {
+		return nil
+	}
+	return s.recorder.RecordPrices(j.Route.DepartureNode, j.Route.ArrivalNode, j.Date, trips)
+}
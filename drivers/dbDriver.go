@@ -8,7 +8,7 @@ const (
 	neighboursBelongsToCityQuery = "MATCH (a)-[r:BelongsTo]-(b:City) WHERE id(a)=$id AND id(b)<>$s RETURN labels(b)[0], id(b), properties(b) " +
 		"UNION MATCH (a:City)-[r:BelongsTo]-(b) WHERE id(a)=$id AND id(a)=$s RETURN labels(b)[0], id(b), properties(b)"
 	neighboursBelongsToThroughCityQuery = "MATCH (a)-[r1:BelongsTo]->(b:City)-[r2:BelongsTo]-(c) WHERE id(a)=$id RETURN labels(c)[0], id(c), properties(c) ORDER BY id(r2)"
-	neighboursGenQuery                  = "MATCH (a)-[r:Gen]->(b)	WHERE id(a)=$id RETURN r.price, r.provider, labels(b)[0], id(b), properties(b) ORDER BY id(r)"
+	neighboursGenQuery                  = "MATCH (a)-[r:Gen]->(b)	WHERE id(a)=$id RETURN r.price, r.provider, labels(b)[0], id(b), properties(b), r.currency ORDER BY id(r)"
 
 	nodeInfoQuery = "MATCH (n) WHERE id(n)=$id RETURN labels(n)[0], properties(n)"
 )
@@ -1,20 +1,26 @@
 package drivers
 
 import (
+	"time"
+
 	"github.com/jcasado94/connecc/mongo"
 	mongoEntity "github.com/jcasado94/connecc/mongo/entity"
 	mongoService "github.com/jcasado94/connecc/mongo/service"
+	"github.com/jcasado94/connecc/scraping"
 )
 
 const (
 	mongoEndpoint    = "localhost:27017"
 	mongoDb          = "tripz"
 	mongoAvgPriceCol = "averagePrice"
+	mongoPriceObsCol = "priceObservation"
+	mongoRollupCol   = "priceRollup"
 )
 
 type MongoDriver struct {
 	session   *mongo.Session
 	apService *mongoService.AveragePriceService
+	poService *mongoService.PriceObservationService
 }
 
 func NewMongoDriver() (MongoDriver, error) {
@@ -22,14 +28,22 @@ func NewMongoDriver() (MongoDriver, error) {
 	if err != nil {
 		return MongoDriver{}, err
 	}
+	apService, err := mongoService.NewAveragePriceService(session, mongoDb, mongoAvgPriceCol)
+	if err != nil {
+		session.Close()
+		return MongoDriver{}, err
+	}
 	return MongoDriver{
 		session:   session,
-		apService: mongoService.NewAveragePriceService(session, mongoDb, mongoAvgPriceCol),
+		apService: apService,
+		poService: mongoService.NewPriceObservationService(session, mongoDb, mongoPriceObsCol, mongoRollupCol, mongoService.DefaultPriceHistorySettings),
 	}, nil
 }
 
-func (md *MongoDriver) GetAvgPrice(s, t int) (float64, error) {
-	price, err := md.apService.GetAverage(s, t)
+// GetAvgPrice returns the average price from s to t of the trips travelling on travelDate, searched on searchDate, or
+// of every trip if travelDate is zero.
+func (md *MongoDriver) GetAvgPrice(s, t int, travelDate, searchDate time.Time) (float64, error) {
+	price, err := md.apService.GetAverage(s, t, travelDate, searchDate)
 	_, missingDocument := err.(mongoService.AvgDocumentNotFoundError)
 	_, missingEntry := err.(mongoService.AvgNotFoundError)
 	if missingDocument {
@@ -42,6 +56,51 @@ func (md *MongoDriver) GetAvgPrice(s, t int) (float64, error) {
 	return price, nil
 }
 
+// UpdateAvgPrice adds a newly scraped price to the average price of the trips from s to t, travelling on travelDate
+// and searched on searchDate, unless a price was already added under updateId.
+func (md *MongoDriver) UpdateAvgPrice(s, t int, price float64, travelDate, searchDate time.Time, updateId string) error {
+	return md.apService.UpdateAverage(s, t, price, travelDate, searchDate, updateId)
+}
+
+// GetPriceStats returns the average price from s to t along with the spread of the prices it was built from, such as
+// their variance and quantiles.
+func (md *MongoDriver) GetPriceStats(s, t int) (mongoEntity.Average, error) {
+	return md.apService.GetStats(s, t)
+}
+
+// RecordPrices adds the adult price of every fare of trips, found from s to t travelling on date, to the price
+// history. Trips with no ScrapedAt are taken as scraped now.
+func (md *MongoDriver) RecordPrices(s, t int, date time.Time, trips []*scraping.Trip) error {
+	now := time.Now()
+	observations := make([]*mongoEntity.PriceObservation, 0)
+	for _, trip := range trips {
+		scrapedAt := trip.ScrapedAt
+		if scrapedAt.IsZero() {
+			scrapedAt = now
+		}
+		key := trip.Key()
+		for _, f := range trip.Fares {
+			observations = append(observations, &mongoEntity.PriceObservation{
+				NodeId:     s,
+				TargetId:   t,
+				Provider:   trip.Provider,
+				TravelDate: date,
+				ScrapedAt:  scrapedAt,
+				TripKey:    key,
+				FareType:   f.Type,
+				Price:      f.Price.Float64(),
+				Currency:   f.Price.Currency,
+			})
+		}
+	}
+	return md.poService.InsertObservations(observations)
+}
+
+// RollupPrices sums up the old observations of the price history.
+func (md *MongoDriver) RollupPrices() (int, error) {
+	return md.poService.Rollup(time.Now())
+}
+
 func (md *MongoDriver) createAvgPriceDocument(s, t int) (price float64, err error) {
 	item, price := mongoEntity.NewAveragePrice(s, t)
 	return price, md.apService.CreateAveragePrice(&item)
@@ -0,0 +1,49 @@
+package drivers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jcasado94/connecc/mongo"
+	mongoEntity "github.com/jcasado94/connecc/mongo/entity"
+	mongoService "github.com/jcasado94/connecc/mongo/service"
+	"github.com/jcasado94/connecc/scraping"
+)
+
+const mongoTripCacheCol = "tripCache"
+
+// MongoTripCache is a scraping.TripCache kept in Mongo, so that it's shared by every process and survives restarts.
+type MongoTripCache struct {
+	tcService *mongoService.TripCacheService
+}
+
+func NewMongoTripCache(session *mongo.Session) *MongoTripCache {
+	return &MongoTripCache{
+		tcService: mongoService.NewTripCacheService(session, mongoDb, mongoTripCacheCol),
+	}
+}
+
+func (c *MongoTripCache) Get(key string) ([]*scraping.Trip, bool, error) {
+	ct, ok, err := c.tcService.GetCachedTrips(key, time.Now())
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var trips []*scraping.Trip
+	err = json.Unmarshal(ct.Trips, &trips)
+	if err != nil {
+		return nil, false, err
+	}
+	return trips, true, nil
+}
+
+func (c *MongoTripCache) Set(key string, trips []*scraping.Trip, ttl time.Duration) error {
+	data, err := json.Marshal(trips)
+	if err != nil {
+		return err
+	}
+	return c.tcService.SetCachedTrips(&mongoEntity.CachedTrips{
+		Key:       key,
+		Trips:     data,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+}
@@ -0,0 +1,229 @@
+package graph
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const cacheTestNodes = 50
+
+// fakeLoader connects every node n to the next three for 10, counting how many times the connections of each node are loaded.
+type fakeLoader struct {
+	mu        sync.Mutex
+	genLoads  map[int]int
+	cityLoads map[int]int
+}
+
+func newFakeLoader() *fakeLoader {
+	return &fakeLoader{genLoads: make(map[int]int), cityLoads: make(map[int]int)}
+}
+
+func (l *fakeLoader) retrieveGenConnections(n int, e *cacheEntry) error {
+	l.mu.Lock()
+	l.genLoads[n]++
+	l.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	for d := 1; d <= 3; d++ {
+		if m := n + d; m < cacheTestNodes {
+			e.setGeneralRelationship(m, d, 10)
+		}
+	}
+	return nil
+}
+
+func (l *fakeLoader) retrieveBelongsToConnections(n int, e *cacheEntry) error {
+	l.mu.Lock()
+	l.cityLoads[n]++
+	l.mu.Unlock()
+	return nil
+}
+
+type cacheGraph struct {
+	cache *genGeaphCache
+}
+
+func (g cacheGraph) S() int { return 0 }
+func (g cacheGraph) T() int { return cacheTestNodes - 1 }
+func (g cacheGraph) Connections(n int) (map[int][]float64, error) {
+	return g.cache.getOrInvalidate(n)
+}
+func (g cacheGraph) FValue(n int) (float64, error) { return 0, nil }
+
+func TestCacheConcurrentSearches(t *testing.T) {
+	loader := newFakeLoader()
+	c := newGenGraphCache(loader)
+	// The cheapest paths take as many hops of 3 as possible.
+	hops := (cacheTestNodes + 1) / 3
+
+	search := func(wg *sync.WaitGroup) {
+		defer wg.Done()
+		path, ok, err := Search(cacheGraph{c})
+		if err != nil || !ok || path.Price != float64(hops*10) || len(path.Nodes) != hops+1 {
+			t.Errorf("Expected a path of %d hops, got %v, %v", hops, path, err)
+		}
+		for n := 0; n < cacheTestNodes; n++ {
+			if info, ok := c.info(n); ok && len(info[n+1]) > 1 {
+				t.Errorf("Node %d has duplicated connections: %v", n, info)
+			}
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go search(&wg)
+	}
+	wg.Wait()
+	for n, loads := range loader.genLoads {
+		if loads != 1 || loader.cityLoads[n] != 1 {
+			t.Errorf("Expected node %d to be loaded once, got %d and %d", n, loads, loader.cityLoads[n])
+		}
+	}
+
+	// Entries too old are loaded again, once.
+	loads := make(map[int]int)
+	for n, l := range loader.genLoads {
+		loads[n] = l
+	}
+	c.mu.Lock()
+	for _, e := range c.entries {
+		e.loadedAt = e.loadedAt.Add(-invalidateAgeGenRel * 2)
+	}
+	c.mu.Unlock()
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < cacheTestNodes; n++ {
+				if _, err := c.getOrInvalidate(n); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for n := 0; n < cacheTestNodes; n++ {
+		if loader.genLoads[n] != loads[n]+1 {
+			t.Errorf("Expected node %d to be loaded once more than %d times, got %d", n, loads[n], loader.genLoads[n])
+		}
+	}
+}
+
+// routeLoader loads the Gen connections of node 0 from routes, keyed by neighbour, and makes it belong to city.
+type routeLoader struct {
+	routes map[int]genConnectionInfo
+	prices map[int]float64
+	city   int
+	err    error
+}
+
+func (l *routeLoader) retrieveGenConnections(n int, e *cacheEntry) error {
+	if l.err != nil {
+		return l.err
+	}
+	for m, info := range l.routes {
+		e.setGeneralRelationship(m, info.provider, l.prices[m])
+	}
+	return nil
+}
+
+func (l *routeLoader) retrieveBelongsToConnections(n int, e *cacheEntry) error {
+	e.setBelongsToRelationship(l.city, defaultCostBelongsToCity)
+	return nil
+}
+
+func TestRefreshReplacesConnections(t *testing.T) {
+	loader := &routeLoader{
+		routes: map[int]genConnectionInfo{1: {provider: 1}, 2: {provider: 2}},
+		prices: map[int]float64{1: 10, 2: 20},
+		city:   9,
+	}
+	g := &genGraph{s: 0, t: 1, heuristic: ZeroHeuristic}
+	g.cache = newGenGraphCache(loader)
+
+	before := g.Snapshot()
+	if _, err := before.Connections(0); err != nil {
+		t.Fatal(err)
+	}
+
+	loader.routes = map[int]genConnectionInfo{1: {provider: 3}}
+	loader.prices = map[int]float64{1: 15}
+	loader.city = 8
+	e, _ := g.cache.entry(0)
+	e.loadedAt = e.loadedAt.Add(-invalidateAgeGenRel * 2)
+
+	connections, err := g.Connections(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int][]float64{1: {15}, 8: {defaultCostBelongsToCity}}
+	if !reflect.DeepEqual(connections, expected) {
+		t.Errorf("Expected %v,\ngot\n %v", expected, connections)
+	}
+	if p := g.Providers(0, 1); !reflect.DeepEqual(p, []string{"3"}) {
+		t.Errorf("Expected providers [3], got %v", p)
+	}
+
+	// The snapshot taken before the refresh keeps seeing the old version.
+	connections, err = before.Connections(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = map[int][]float64{1: {10}, 2: {20}, 9: {defaultCostBelongsToCity}}
+	if !reflect.DeepEqual(connections, expected) {
+		t.Errorf("Expected %v,\ngot\n %v", expected, connections)
+	}
+	if p, _ := before.Providers(0, 1); !reflect.DeepEqual(p, []string{"1"}) {
+		t.Errorf("Expected providers [1], got %v", p)
+	}
+	if v := before.Version(0); v != 0 {
+		t.Errorf("Expected version 0, got %d", v)
+	}
+
+	after := g.Snapshot()
+	if v := after.Version(0); v != -1 {
+		t.Errorf("Expected no version before loading, got %d", v)
+	}
+	if p, _ := after.Providers(0, 1); !reflect.DeepEqual(p, []string{"3"}) {
+		t.Errorf("Expected providers [3], got %v", p)
+	}
+	if v := after.Version(0); v != 1 {
+		t.Errorf("Expected version 1, got %d", v)
+	}
+}
+
+func TestRefreshFailureKeepsEntry(t *testing.T) {
+	loader := &routeLoader{
+		routes: map[int]genConnectionInfo{1: {provider: 1}},
+		prices: map[int]float64{1: 10},
+		city:   9,
+	}
+	c := newGenGraphCache(loader)
+	if _, err := c.getOrInvalidate(0); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := c.entry(0)
+	old.loadedAt = old.loadedAt.Add(-invalidateAgeGenRel * 2)
+
+	// The stale entry is served while the database is down, and refreshed once it's back.
+	loader.err = newDbUnavailableError(fmt.Errorf("connection reset"))
+	connections, err := c.getOrInvalidate(0)
+	if err != nil || !reflect.DeepEqual(connections, old.connections) {
+		t.Errorf("Expected the old connections, got %v, %v", connections, err)
+	}
+	if e, _ := c.entry(0); e != old {
+		t.Error("Expected the old entry to stay cached")
+	}
+	loader.err = nil
+	loader.prices[1] = 15
+	connections, err = c.getOrInvalidate(0)
+	expected := map[int][]float64{1: {15}, 9: {defaultCostBelongsToCity}}
+	if err != nil || !reflect.DeepEqual(connections, expected) {
+		t.Errorf("Expected %v, got %v, %v", expected, connections, err)
+	}
+	if e, _ := c.entry(0); e.version != old.version+1 {
+		t.Errorf("Expected version %d, got %d", old.version+1, e.version)
+	}
+}
@@ -7,6 +7,7 @@ const defaultCostBelongsTo = 100.0
 
 type genConnection struct {
 	Price    float64
+	Currency string
 	Provider int
 	n        node
 }
@@ -19,14 +20,31 @@ func buildGenNeighbours(result neo4j.Result) (genNeighbours, error) {
 	var next bool
 	for next = result.Next(); next; next = result.Next() {
 		rec := result.Record()
+		price, err := recordFloat(rec, 0)
+		if err != nil {
+			return nil, err
+		}
+		provider, err := recordInt(rec, 1)
+		if err != nil {
+			return nil, err
+		}
+		n, err := recordNode(rec, 2)
+		if err != nil {
+			return nil, err
+		}
+		currency, _ := recordField(rec, 5).(string)
 		resp = append(resp, genConnection{
-			Price:    rec.GetByIndex(0).(float64),
-			Provider: int(rec.GetByIndex(1).(int64)),
-			n:        newNode(rec.GetByIndex(2).(string), int(rec.GetByIndex(3).(int64)), rec.GetByIndex(4).(map[string]interface{})),
+			Price:    price,
+			Currency: currency,
+			Provider: provider,
+			n:        n,
 		})
 	}
 
-	return resp, result.Err()
+	if result.Err() != nil {
+		return nil, newDbUnavailableError(result.Err())
+	}
+	return resp, nil
 }
 
 type belongsToConnection struct {
@@ -41,31 +59,94 @@ func buildBelongsToNeighbours(resultCity, resultThroughCity neo4j.Result) (belon
 
 	var next bool
 	for next = resultCity.Next(); next; next = resultCity.Next() {
-		rec := resultCity.Record()
+		n, err := recordNode(resultCity.Record(), 0)
+		if err != nil {
+			return nil, err
+		}
 		resp = append(resp, belongsToConnection{
 			Cost: defaultCostBelongsToCity,
-			n:    newNode(rec.GetByIndex(0).(string), int(rec.GetByIndex(1).(int64)), rec.GetByIndex(2).(map[string]interface{})),
+			n:    n,
 		})
 	}
-	if resultCity.Next() {
-
-	}
 
 	if resultCity.Err() != nil {
-		return nil, resultCity.Err()
+		return nil, newDbUnavailableError(resultCity.Err())
 	}
 
 	for next = resultThroughCity.Next(); next; next = resultThroughCity.Next() {
-		rec := resultThroughCity.Record()
+		n, err := recordNode(resultThroughCity.Record(), 0)
+		if err != nil {
+			return nil, err
+		}
 		resp = append(resp, belongsToConnection{
 			Cost: defaultCostBelongsTo, // find cost function (google maps?)
-			n:    newNode(rec.GetByIndex(0).(string), int(rec.GetByIndex(1).(int64)), rec.GetByIndex(2).(map[string]interface{})),
+			n:    n,
 		})
 	}
 
 	if resultThroughCity.Err() != nil {
-		return nil, resultThroughCity.Err()
+		return nil, newDbUnavailableError(resultThroughCity.Err())
 	}
 
 	return resp, nil
 }
+
+// recordField returns the index-th field of rec, or nil if it has no such field.
+func recordField(rec neo4j.Record, index int) interface{} {
+	if index >= len(rec.Values()) {
+		return nil
+	}
+	return rec.GetByIndex(index)
+}
+
+func recordString(rec neo4j.Record, index int) (string, error) {
+	s, ok := recordField(rec, index).(string)
+	if !ok {
+		return "", newMalformedRecordError(index, "a string", recordField(rec, index))
+	}
+	return s, nil
+}
+
+func recordInt(rec neo4j.Record, index int) (int, error) {
+	i, ok := recordField(rec, index).(int64)
+	if !ok {
+		return 0, newMalformedRecordError(index, "an integer", recordField(rec, index))
+	}
+	return int(i), nil
+}
+
+// recordFloat also reads integers, as prices stored without decimals.
+func recordFloat(rec neo4j.Record, index int) (float64, error) {
+	switch v := recordField(rec, index).(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	}
+	return 0, newMalformedRecordError(index, "a number", recordField(rec, index))
+}
+
+func recordMap(rec neo4j.Record, index int) (map[string]interface{}, error) {
+	m, ok := recordField(rec, index).(map[string]interface{})
+	if !ok {
+		return nil, newMalformedRecordError(index, "a map", recordField(rec, index))
+	}
+	return m, nil
+}
+
+// recordNode reads a node from the label, id and properties at index and the two following fields of rec.
+func recordNode(rec neo4j.Record, index int) (node, error) {
+	label, err := recordString(rec, index)
+	if err != nil {
+		return nil, err
+	}
+	id, err := recordInt(rec, index+1)
+	if err != nil {
+		return nil, err
+	}
+	params, err := recordMap(rec, index+2)
+	if err != nil {
+		return nil, err
+	}
+	return newNode(label, id, params)
+}
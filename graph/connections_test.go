@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/neo4j"
+)
+
+type fakeRecord []interface{}
+
+func (r fakeRecord) Keys() []string                     { return nil }
+func (r fakeRecord) Values() []interface{}              { return r }
+func (r fakeRecord) Get(key string) (interface{}, bool) { return nil, false }
+func (r fakeRecord) GetByIndex(index int) interface{}   { return r[index] }
+
+type fakeResult struct {
+	records []fakeRecord
+	i       int
+	err     error
+}
+
+func (r *fakeResult) Keys() ([]string, error)               { return nil, nil }
+func (r *fakeResult) Err() error                            { return r.err }
+func (r *fakeResult) Record() neo4j.Record                  { return r.records[r.i-1] }
+func (r *fakeResult) Summary() (neo4j.ResultSummary, error) { return nil, nil }
+func (r *fakeResult) Consume() (neo4j.ResultSummary, error) { return nil, nil }
+func (r *fakeResult) Next() bool {
+	if r.i >= len(r.records) {
+		return false
+	}
+	r.i++
+	return true
+}
+
+func TestBuildGenNeighbours(t *testing.T) {
+	airport := map[string]interface{}{"code": "JFK", "latitude": 40.64, "longitude": -73.78}
+	gn, err := buildGenNeighbours(&fakeResult{records: []fakeRecord{
+		{200.0, int64(1), "Airport", int64(7), airport, "USD"},
+		{int64(150), int64(2), "City", int64(8), map[string]interface{}{"name": "New York"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gn) != 2 || gn[0].Price != 200 || gn[0].Currency != "USD" || gn[1].Price != 150 || gn[1].Currency != "" {
+		t.Errorf("Unexpected neighbours %+v", gn)
+	}
+	if c, ok := gn[0].n.Location(); !ok || c.Lat != 40.64 {
+		t.Errorf("Expected JFK to be located, got %v", c)
+	}
+
+	tests := []struct {
+		name   string
+		result *fakeResult
+		check  func(err error) bool
+	}{
+		{"price", &fakeResult{records: []fakeRecord{{"200", int64(1), "Airport", int64(7), airport}}}, isMalformedRecord},
+		{"short", &fakeResult{records: []fakeRecord{{200.0, int64(1), "Airport"}}}, isMalformedRecord},
+		{"code", &fakeResult{records: []fakeRecord{{200.0, int64(1), "Airport", int64(7), map[string]interface{}{}}}}, isMalformedRecord},
+		{"unavailable", &fakeResult{err: fmt.Errorf("connection reset")}, func(err error) bool {
+			_, ok := err.(DbUnavailableError)
+			return ok
+		}},
+	}
+	for _, test := range tests {
+		if _, err := buildGenNeighbours(test.result); !test.check(err) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func isMalformedRecord(err error) bool {
+	_, ok := err.(MalformedRecordError)
+	return ok
+}
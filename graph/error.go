@@ -0,0 +1,54 @@
+package graph
+
+import "fmt"
+
+type NodeNotFoundError struct {
+	What string
+}
+
+func newNodeNotFoundError(id int) NodeNotFoundError {
+	return NodeNotFoundError{
+		What: fmt.Sprintf("No node found with id %d", id),
+	}
+}
+
+func (e NodeNotFoundError) Error() string {
+	return e.What
+}
+
+// DbUnavailableError is returned when Neo4j or Mongo fail to answer, which may well be transient.
+type DbUnavailableError struct {
+	What string
+	Err  error
+}
+
+func newDbUnavailableError(err error) DbUnavailableError {
+	return DbUnavailableError{
+		What: fmt.Sprintf("Database unavailable: %v", err),
+		Err:  err,
+	}
+}
+
+func (e DbUnavailableError) Error() string {
+	return e.What
+}
+
+type MalformedRecordError struct {
+	What string
+}
+
+func newMalformedRecordError(index int, expected string, value interface{}) MalformedRecordError {
+	return MalformedRecordError{
+		What: fmt.Sprintf("Malformed record. Expected field %d to be %s, got %T", index, expected, value),
+	}
+}
+
+func newMissingPropertyError(label string, id int, property string) MalformedRecordError {
+	return MalformedRecordError{
+		What: fmt.Sprintf("Malformed record. %s %d has no %s", label, id, property),
+	}
+}
+
+func (e MalformedRecordError) Error() string {
+	return e.What
+}
@@ -1,23 +1,38 @@
 package graph
 
 import (
+	"log"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/jcasado94/connecc/drivers"
+	"github.com/jcasado94/connecc/money"
+	"github.com/jcasado94/connecc/providers"
 	cmap "github.com/orcaman/concurrent-map"
+	"golang.org/x/sync/singleflight"
 )
 
 var invalidateAgeGenRel = time.Hour * 24
 
+const defaultReportingCurrency = "USD"
+
 type genGraph struct {
-	mDriver  drivers.MongoDriver
-	dbDriver drivers.DbDriver
-	cache    genGeaphCache
-	s, t     int
+	mDriver   drivers.MongoDriver
+	dbDriver  drivers.DbDriver
+	cache     *genGeaphCache
+	s, t      int
+	currency  string
+	converter money.Converter
+	providers *providers.Catalogue
+	// date is the travel date of the search, zero if unknown.
+	date      time.Time
+	heuristic Heuristic
 }
 
-func NewGenGraph(s, t int, dbEndpoint, dbUsername, dbPw string) (*genGraph, error) {
+// NewGenGraph creates the graph to search from s to t for trips travelling on travelDate, which FValue estimates the
+// price of with the averages of its bucket. A zero travelDate estimates with the averages of every trip.
+func NewGenGraph(s, t int, travelDate time.Time, dbEndpoint, dbUsername, dbPw string) (*genGraph, error) {
 	driver, err := drivers.NewDbDriver(dbEndpoint, dbUsername, dbPw, false)
 	if err != nil {
 		return &genGraph{}, err
@@ -27,13 +42,17 @@ func NewGenGraph(s, t int, dbEndpoint, dbUsername, dbPw string) (*genGraph, erro
 		return &genGraph{}, err
 	}
 	g := genGraph{
-		mDriver:  mDriver,
-		dbDriver: driver,
-		s:        s,
-		t:        t,
+		mDriver:   mDriver,
+		dbDriver:  driver,
+		s:         s,
+		t:         t,
+		currency:  defaultReportingCurrency,
+		converter: money.NewRateTable(defaultReportingCurrency, nil),
+		date:      travelDate,
 	}
 
 	g.cache = newGenGraphCache(&g)
+	g.heuristic = NewAverageHeuristic(&g.mDriver)
 
 	err = g.cacheNodeInfo(s)
 	if err != nil {
@@ -43,36 +62,96 @@ func NewGenGraph(s, t int, dbEndpoint, dbUsername, dbPw string) (*genGraph, erro
 	return &g, nil
 }
 
+// SetReportingCurrency makes the graph compare Gen prices in currency, converting the ones stored in other currencies
+// with converter. Connections already cached keep the prices they were loaded with.
+func (g *genGraph) SetReportingCurrency(currency string, converter money.Converter) {
+	g.currency = currency
+	g.converter = converter
+}
+
+// SetProviders makes the graph check the provider of every Gen relationship against catalogue, taking the currency of
+// prices stored without one from their provider.
+func (g *genGraph) SetProviders(catalogue *providers.Catalogue) {
+	g.providers = catalogue
+}
+
+// SetHeuristic makes FValue estimate with h, as ZeroHeuristic or the ones created by NewMinimumHeuristic or
+// NewDistanceHeuristic. The average price heuristic is used by default.
+func (g *genGraph) SetHeuristic(h Heuristic) {
+	g.heuristic = h
+}
+
+// Locate returns the coordinates of node n, if it's been loaded and has them.
+func (g *genGraph) Locate(n int) (Coordinates, bool) {
+	nodeInt, ok := g.cache.nodesCache.checkGet(n)
+	if !ok {
+		return Coordinates{}, false
+	}
+	return nodeInt.(node).Location()
+}
+
+// Providers returns the names of the providers of the Gen connections from n to m, in the same order as their prices
+// in Connections(n)[m]. Without a catalogue, providers are named after their id. Searches should rather use the ones of
+// their Snapshot, which stay in line with the prices they saw if the node is refreshed meanwhile.
+func (g *genGraph) Providers(n, m int) []string {
+	infos, ok := g.cache.info(n)
+	if !ok {
+		return []string{}
+	}
+	return g.providerNames(infos[m])
+}
+
+func (g *genGraph) providerNames(infos []genConnectionInfo) []string {
+	names := make([]string, 0)
+	for _, info := range infos {
+		if g.providers == nil {
+			names = append(names, strconv.Itoa(info.provider))
+		} else {
+			names = append(names, g.providers.Name(info.provider))
+		}
+	}
+	return names
+}
+
 func (g *genGraph) cacheNodeInfo(id int) error {
 	result, err := g.dbDriver.NodeInfo(id)
+	if err != nil {
+		return newDbUnavailableError(err)
+	}
+	if !result.Next() {
+		if result.Err() != nil {
+			return newDbUnavailableError(result.Err())
+		}
+		return newNodeNotFoundError(id)
+	}
+	rec := result.Record()
+	label, err := recordString(rec, 0)
 	if err != nil {
 		return err
 	}
-	if result.Next() {
-		rec := result.Record()
-		label := rec.GetByIndex(0).(string)
-		params := rec.GetByIndex(1).(map[string]interface{})
-		node := newNode(label, id, params)
-		g.cache.setNode(id, &node)
+	params, err := recordMap(rec, 1)
+	if err != nil {
+		return err
 	}
-	return result.Err()
-}
-
-func (g *genGraph) Connections(n int) map[int][]float64 {
-
-	connections, err := g.cache.getOrInvalidate(n)
+	node, err := newNode(label, id, params)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	return connections
+	g.cache.setNode(id, &node)
+	return nil
+}
 
+// Connections returns the prices of reaching every neighbour of n, loading them from the databases if they aren't
+// cached or are too old.
+func (g *genGraph) Connections(n int) (map[int][]float64, error) {
+	return g.cache.getOrInvalidate(n)
 }
 
-func (g *genGraph) retrieveGenConnections(n int) error {
+func (g *genGraph) retrieveGenConnections(n int, e *cacheEntry) error {
 
 	neighboursGenResult, err := g.dbDriver.NeighboursGen(n)
 	if err != nil {
-		return err
+		return newDbUnavailableError(err)
 	}
 
 	gn, err := buildGenNeighbours(neighboursGenResult)
@@ -80,27 +159,52 @@ func (g *genGraph) retrieveGenConnections(n int) error {
 		return err
 	}
 	for _, gcon := range gn {
+		if g.providers != nil {
+			p, err := g.providers.ById(gcon.Provider)
+			if err != nil {
+				return err
+			}
+			if gcon.Currency == "" {
+				gcon.Currency = p.Currency
+			}
+		}
+		price, err := g.reportingPrice(gcon.Price, gcon.Currency)
+		if err != nil {
+			return err
+		}
 		id := gcon.n.Id()
 		g.cache.setNode(id, &gcon.n)
-		g.cache.setGeneralRelationship(n, id, gcon.Provider, gcon.Price)
+		e.setGeneralRelationship(id, gcon.Provider, price)
 	}
 
 	return nil
 
 }
 
+// reportingPrice converts a Gen price into the reporting currency. Prices stored without currency are assumed to be in it already.
+func (g *genGraph) reportingPrice(price float64, currency string) (float64, error) {
+	if currency == "" || currency == g.currency {
+		return price, nil
+	}
+	converted, err := g.converter.Convert(money.FromFloat(price, currency), g.currency)
+	if err != nil {
+		return 0.0, err
+	}
+	return converted.Float64(), nil
+}
+
 // Get the neighbours through the BelongsTo City node, plus the City node itself, excluding S. City nodes shall return no neighbours, except for S.
-func (g *genGraph) retrieveBelongsToConnections(n int) error {
+func (g *genGraph) retrieveBelongsToConnections(n int, e *cacheEntry) error {
 
 	//concurrent?
 	neighboursBelongsToCityResult, err := g.dbDriver.NeighboursBelongsToCity(n, g.S())
 	if err != nil {
-		return err
+		return newDbUnavailableError(err)
 	}
 
 	neighboursBelongsToThroughCityResult, err := g.dbDriver.NeighboursBelongsToThroughCity(n, g.S())
 	if err != nil {
-		return err
+		return newDbUnavailableError(err)
 	}
 
 	btn, err := buildBelongsToNeighbours(neighboursBelongsToCityResult, neighboursBelongsToThroughCityResult)
@@ -110,7 +214,7 @@ func (g *genGraph) retrieveBelongsToConnections(n int) error {
 	for _, btcon := range btn {
 		id := btcon.n.Id()
 		g.cache.setNode(id, &btcon.n)
-		g.cache.setBelongsToRelationship(n, id, btcon.Cost)
+		e.setBelongsToRelationship(id, btcon.Cost)
 	}
 
 	return nil
@@ -124,29 +228,51 @@ func (g *genGraph) T() int {
 	return g.t
 }
 
-func (g *genGraph) FValue(n int) float64 {
-	avgPrice, err := g.mDriver.GetAvgPrice(n, g.T())
-	if err != nil {
-		panic(err)
-	}
-	return avgPrice
+// FValue estimates the price from n to T with the heuristic of the graph. The heuristics reading prices from Mongo
+// return a DbUnavailableError when it fails to answer.
+func (g *genGraph) FValue(n int) (float64, error) {
+	return g.heuristic(n, g.T(), g.date)
 }
 
+// genGeaphCache holds the nodes and connections loaded by a genGraph. It's safe for concurrent use: the connections of
+// a node are loaded once however many searches ask for them at once, then replaced as a whole when too old, so callers
+// must not modify them.
 type genGeaphCache struct {
-	infoCache            intCMap // map[int]map[int][]genConnectionInfo
-	cache                intCMap // map[int]map[int][]float64
-	connectionsTimeStamp intCMap // map[int]time.Time
-	nodesCache           intCMap // map[int]node
-	g                    *genGraph
+	mu         sync.RWMutex
+	entries    map[int]*cacheEntry
+	loading    singleflight.Group
+	nodesCache intCMap // map[int]node
+	loader     connectionsLoader
+}
+
+// connectionsLoader fills in the connections of a node into an entry. genGraph is one.
+type connectionsLoader interface {
+	retrieveGenConnections(n int, e *cacheEntry) error
+	retrieveBelongsToConnections(n int, e *cacheEntry) error
+}
+
+// cacheEntry holds the connections of a node, along with the providers of its Gen connections, as loaded at loadedAt.
+// Entries are never modified once cached: every refresh of a node caches a new one, with the next version.
+type cacheEntry struct {
+	connections map[int][]float64
+	info        map[int][]genConnectionInfo
+	loadedAt    time.Time
+	version     int
+}
+
+func newCacheEntry() *cacheEntry {
+	return &cacheEntry{
+		connections: make(map[int][]float64),
+		info:        make(map[int][]genConnectionInfo),
+		loadedAt:    time.Now(),
+	}
 }
 
-func newGenGraphCache(g *genGraph) genGeaphCache {
-	return genGeaphCache{
-		infoCache:            newIntCMap(),
-		cache:                newIntCMap(),
-		connectionsTimeStamp: newIntCMap(),
-		nodesCache:           newIntCMap(),
-		g:                    g,
+func newGenGraphCache(loader connectionsLoader) *genGeaphCache {
+	return &genGeaphCache{
+		entries:    make(map[int]*cacheEntry),
+		nodesCache: newIntCMap(),
+		loader:     loader,
 	}
 }
 
@@ -178,64 +304,122 @@ func (m *intCMap) set(key int, val interface{}) {
 }
 
 func (m *intCMap) checkSet(key int, val interface{}) {
-	if _, ok := m.checkGet(key); !ok {
-		m.set(key, val)
-	}
+	m.cm.SetIfAbsent(strconv.Itoa(key), val)
+}
+
+func (c *genGeaphCache) entry(n int) (*cacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[n]
+	return e, ok
+}
+
+func (c *genGeaphCache) setEntry(n int, e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[n] = e
 }
 
+func fresh(e *cacheEntry) bool {
+	return time.Now().Sub(e.loadedAt) <= invalidateAgeGenRel
+}
+
+// getOrInvalidate returns the connections of n, loading them first if needed.
 func (c *genGeaphCache) getOrInvalidate(n int) (map[int][]float64, error) {
-	var err error
-	tInt, ok := c.connectionsTimeStamp.checkGet(n)
-	if !ok {
-		err = c.initializeCache(n)
-	} else if time.Now().Sub(tInt.(time.Time)) > invalidateAgeGenRel {
-		err = c.invalidateCache(n)
+	e, err := c.getEntry(n)
+	if err != nil {
+		return nil, err
 	}
-	return c.cache.get(n).(map[int][]float64), err
+	return e.connections, nil
 }
 
-func (c *genGeaphCache) initializeCache(n int) error {
-	c.connectionsTimeStamp.set(n, time.Now())
-	c.cache.set(n, make(map[int][]float64))
-	c.infoCache.set(n, make(map[int][]genConnectionInfo))
-	err := c.g.retrieveGenConnections(n)
+// getEntry returns the entry of n, loading it first if needed. Searches asking for the same node at once wait for a
+// single load. Entries that fail to load are not cached, so that they're loaded again next time, while the ones that
+// fail to refresh keep being served until a refresh succeeds.
+func (c *genGeaphCache) getEntry(n int) (*cacheEntry, error) {
+	if e, ok := c.entry(n); ok && fresh(e) {
+		return e, nil
+	}
+	eInt, err, _ := c.loading.Do(strconv.Itoa(n), func() (interface{}, error) {
+		e, ok := c.entry(n)
+		switch {
+		case ok && fresh(e):
+			return e, nil
+		case ok:
+			refreshed, err := c.invalidateCache(n, e)
+			if err != nil {
+				log.Printf("Graph. Couldn't refresh the connections of node %d, keeping the ones loaded at %v: %v", n, e.loadedAt, err)
+				return e, nil
+			}
+			return refreshed, nil
+		}
+		return c.initializeCache(n)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = c.g.retrieveBelongsToConnections(n)
+	return eInt.(*cacheEntry), nil
+}
+
+func (c *genGeaphCache) initializeCache(n int) (*cacheEntry, error) {
+	e, err := c.load(n)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	c.setEntry(n, e)
+	return e, nil
 }
 
-func (c *genGeaphCache) invalidateCache(n int) error {
-	c.connectionsTimeStamp.set(n, time.Now())
-	err := c.g.retrieveGenConnections(n)
+// invalidateCache replaces the old entry of n with one loaded from scratch, so that connections removed since are gone.
+// Searches still using the old entry keep seeing it whole.
+func (c *genGeaphCache) invalidateCache(n int, old *cacheEntry) (*cacheEntry, error) {
+	e, err := c.load(n)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	e.version = old.version + 1
+	c.setEntry(n, e)
+	return e, nil
+}
+
+func (c *genGeaphCache) load(n int) (*cacheEntry, error) {
+	e := newCacheEntry()
+	err := c.loader.retrieveGenConnections(n, e)
+	if err != nil {
+		return nil, err
+	}
+	err = c.loader.retrieveBelongsToConnections(n, e)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// info returns the providers of the Gen connections of n, if they're loaded.
+func (c *genGeaphCache) info(n int) (map[int][]genConnectionInfo, bool) {
+	e, ok := c.entry(n)
+	if !ok {
+		return nil, false
+	}
+	return e.info, true
 }
 
-func (c *genGeaphCache) setGeneralRelationship(n, id, provider int, price float64) {
-	mCon := c.cache.get(n).(map[int][]float64)
-	mConInfo := c.infoCache.get(n).(map[int][]genConnectionInfo)
-	if _, exists := mCon[id]; !exists {
-		mCon[id] = make([]float64, 0)
-		mConInfo[id] = make([]genConnectionInfo, 0)
+func (e *cacheEntry) setGeneralRelationship(id, provider int, price float64) {
+	if _, exists := e.connections[id]; !exists {
+		e.connections[id] = make([]float64, 0)
+		e.info[id] = make([]genConnectionInfo, 0)
 	}
-	mCon[id] = append(mCon[id], price)
-	mConInfo[id] = append(mConInfo[id], genConnectionInfo{provider: provider})
+	e.connections[id] = append(e.connections[id], price)
+	e.info[id] = append(e.info[id], genConnectionInfo{provider: provider})
 }
 
-func (c *genGeaphCache) setBelongsToRelationship(n, id int, cost float64) {
-	mCon := c.cache.get(n).(map[int][]float64)
-	if _, exists := mCon[id]; !exists {
-		mCon[id] = []float64{cost}
+func (e *cacheEntry) setBelongsToRelationship(id int, cost float64) {
+	if _, exists := e.connections[id]; !exists {
+		e.connections[id] = []float64{cost}
 	}
 }
 
+// setNode caches n, replacing the node loaded before with the same id, so that refreshes pick up its latest properties.
 func (c *genGeaphCache) setNode(id int, n *node) {
-	c.nodesCache.checkSet(id, *n)
+	c.nodesCache.set(id, *n)
 }
@@ -105,7 +105,7 @@ func newMockGenGraph(session neo4j.Session, t *testing.T) (g *genGraph, ids []in
 	idYYZ, idJFK, idLGA, idToronto, idNewYork := ids[0], ids[1], ids[2], ids[3], ids[4]
 	t.Logf("IdYYZ: %d\nIdJFK: %d\nIdLGA: %d\nIdToronto: %d\nIdNewYork: %d\n", idYYZ, idJFK, idLGA, idToronto, idNewYork)
 
-	g, err = NewGenGraph(idNewYork, idToronto, dbTestEndpoint, dbTestUsername, dbTestPw)
+	g, err = NewGenGraph(idNewYork, idToronto, time.Time{}, dbTestEndpoint, dbTestUsername, dbTestPw)
 	if err != nil {
 		t.Fail()
 	}
@@ -132,7 +132,10 @@ func TestConnections(t *testing.T) {
 		}
 		for _, tc := range testCases {
 			t.Run(fmt.Sprintf("Connections for %d", tc.id), func(t *testing.T) {
-				connections := g.Connections(tc.id)
+				connections, err := g.Connections(tc.id)
+				if err != nil {
+					t.Fatal(err)
+				}
 				if !reflect.DeepEqual(tc.expectedConnections, connections) {
 					t.Errorf("Expected %v\ngot\n%v", tc.expectedConnections, connections)
 					cleanDb(session)
@@ -184,49 +187,32 @@ func TestNewGenGraph(t *testing.T) {
 }
 
 func TestSetBelongsToRelationship(t *testing.T) {
-	driver, session := newSessionTestGraph()
-	defer driver.Close()
-	defer session.Close()
-	g, ids := newMockGenGraph(session, t)
-	c := &g.cache
-	idNewYork := ids[4]
+	e := newCacheEntry()
 	expectedMap := map[int][]float64{
 		1: []float64{0.0},
 	}
-	c.cache.set(idNewYork, make(map[int][]float64))
-	c.setBelongsToRelationship(idNewYork, 1, 0.0)
-	cons := c.cache.get(idNewYork).(map[int][]float64)
-	if !reflect.DeepEqual(expectedMap, cons) {
-		t.Errorf("Expected %v,\ngot %v", expectedMap, cons)
+	e.setBelongsToRelationship(1, 0.0)
+	e.setBelongsToRelationship(1, 5.0)
+	if !reflect.DeepEqual(expectedMap, e.connections) {
+		t.Errorf("Expected %v,\ngot %v", expectedMap, e.connections)
 	}
-	cleanDb(session)
 }
 
 func TestSetGeneralRelationship(t *testing.T) {
-	driver, session := newSessionTestGraph()
-	defer driver.Close()
-	defer session.Close()
-	g, ids := newMockGenGraph(session, t)
-	c := &g.cache
-	idNewYork := ids[4]
-	c.cache.set(idNewYork, make(map[int][]float64))
-	c.infoCache.set(idNewYork, make(map[int][]genConnectionInfo))
+	e := newCacheEntry()
 	expectedCacheMap := map[int][]float64{
 		1: []float64{1.0},
 	}
 	expectedInfoCacheMap := map[int][]genConnectionInfo{
 		1: []genConnectionInfo{genConnectionInfo{provider: 0}},
 	}
-	c.setGeneralRelationship(idNewYork, 1, 0, 1.0)
-	consCache := c.cache.get(idNewYork).(map[int][]float64)
-	consInfoCache := c.infoCache.get(idNewYork).(map[int][]genConnectionInfo)
-	if !reflect.DeepEqual(expectedCacheMap, consCache) {
-		t.Errorf("Expected %v,\ngot\n %v", expectedCacheMap, consCache)
+	e.setGeneralRelationship(1, 0, 1.0)
+	if !reflect.DeepEqual(expectedCacheMap, e.connections) {
+		t.Errorf("Expected %v,\ngot\n %v", expectedCacheMap, e.connections)
 	}
-	if !reflect.DeepEqual(expectedInfoCacheMap, consInfoCache) {
-		t.Errorf("Expected %v,\ngot\n %v", expectedInfoCacheMap, consInfoCache)
+	if !reflect.DeepEqual(expectedInfoCacheMap, e.info) {
+		t.Errorf("Expected %v,\ngot\n %v", expectedInfoCacheMap, e.info)
 	}
-	cleanDb(session)
 }
 
 func TestInvlaidateCache(t *testing.T) {
@@ -234,23 +220,30 @@ func TestInvlaidateCache(t *testing.T) {
 	defer driver.Close()
 	defer session.Close()
 	g, ids := newMockGenGraph(session, t)
-	c := &g.cache
-	idYYZ, idJFK := ids[0], ids[1]
-	c.cache.set(idYYZ, make(map[int][]float64))
-	c.infoCache.set(idYYZ, make(map[int][]genConnectionInfo))
-	now := time.Now()
-	c.connectionsTimeStamp.set(idYYZ, now)
-	c.invalidateCache(idYYZ)
-	expectedCacheMap := map[int][]float64{idJFK: []float64{200.0}}
+	c := g.cache
+	idYYZ, idJFK, idToronto := ids[0], ids[1], ids[3]
+	old := newCacheEntry()
+	old.loadedAt = time.Now().Add(-invalidateAgeGenRel * 2)
+	old.setGeneralRelationship(idJFK, 0, 200.0)
+	old.setBelongsToRelationship(idJFK+100, defaultCostBelongsTo)
+	c.setEntry(idYYZ, old)
+	e, err := c.invalidateCache(idYYZ, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCacheMap := map[int][]float64{idJFK: []float64{200.0}, idToronto: []float64{defaultCostBelongsToCity}}
 	expectedInfoCacheMap := map[int][]genConnectionInfo{idJFK: []genConnectionInfo{genConnectionInfo{provider: 0}}}
-	if !reflect.DeepEqual(c.cache.get(idYYZ), expectedCacheMap) {
-		t.Errorf("Expected %v,\ngot\n %v", expectedCacheMap, c.cache.get(idYYZ))
+	if !reflect.DeepEqual(e.connections, expectedCacheMap) {
+		t.Errorf("Expected %v,\ngot\n %v", expectedCacheMap, e.connections)
 	}
-	if !reflect.DeepEqual(c.infoCache.get(idYYZ), expectedInfoCacheMap) {
-		t.Errorf("Expected %v,\ngot\n %v", expectedInfoCacheMap, c.infoCache.get(idYYZ))
+	if !reflect.DeepEqual(e.info, expectedInfoCacheMap) {
+		t.Errorf("Expected %v,\ngot\n %v", expectedInfoCacheMap, e.info)
 	}
-	if c.connectionsTimeStamp.get(idYYZ).(time.Time).Equal(now) {
-		t.Error("Timestamp hasn't changed.")
+	if cached, _ := c.entry(idYYZ); cached != e || !fresh(cached) || cached.version != old.version+1 {
+		t.Error("Entry wasn't replaced.")
+	}
+	if len(old.connections) != 2 || len(old.connections[idJFK]) != 1 {
+		t.Error("Old entry was modified.")
 	}
 	cleanDb(session)
 }
@@ -260,19 +253,22 @@ func TestInitializeCache(t *testing.T) {
 	defer driver.Close()
 	defer session.Close()
 	g, ids := newMockGenGraph(session, t)
-	c := &g.cache
+	c := g.cache
 	idYYZ, idJFK, idToronto := ids[0], ids[1], ids[3]
 	now := time.Now()
 	expectedCacheMap := map[int][]float64{idJFK: []float64{200.0}, idToronto: []float64{defaultCostBelongsToCity}}
 	expectedInfoCacheMap := map[int][]genConnectionInfo{idJFK: []genConnectionInfo{genConnectionInfo{provider: 0}}}
-	c.initializeCache(idYYZ)
-	if !reflect.DeepEqual(c.cache.get(idYYZ), expectedCacheMap) {
-		t.Errorf("Expected %v,\ngot\n %v", expectedCacheMap, c.cache.get(idYYZ))
+	e, err := c.initializeCache(idYYZ)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(c.infoCache.get(idYYZ), expectedInfoCacheMap) {
-		t.Errorf("Expected %v,\ngot\n %v", expectedInfoCacheMap, c.infoCache.get(idYYZ))
+	if !reflect.DeepEqual(e.connections, expectedCacheMap) {
+		t.Errorf("Expected %v,\ngot\n %v", expectedCacheMap, e.connections)
 	}
-	if c.connectionsTimeStamp.get(idYYZ).(time.Time).Sub(now) <= 0 {
+	if !reflect.DeepEqual(e.info, expectedInfoCacheMap) {
+		t.Errorf("Expected %v,\ngot\n %v", expectedInfoCacheMap, e.info)
+	}
+	if e.loadedAt.Sub(now) < 0 {
 		t.Error("Timestamp was not set")
 	}
 	cleanDb(session)
@@ -283,41 +279,42 @@ func TestGetOrInvalidate(t *testing.T) {
 	defer driver.Close()
 	defer session.Close()
 	g, ids := newMockGenGraph(session, t)
-	c := &g.cache
+	c := g.cache
 	idYYZ, idJFK, idToronto := ids[0], ids[1], ids[3]
 	expectedInfoCacheMap := map[int][]genConnectionInfo{idJFK: []genConnectionInfo{genConnectionInfo{provider: 0}}}
 
-	// No timestamp
+	// No entry
 	expectedCacheMap := map[int][]float64{idJFK: []float64{200.0}, idToronto: []float64{defaultCostBelongsToCity}}
-	c.getOrInvalidate(idYYZ)
-	if _, ok := c.connectionsTimeStamp.checkGet(idYYZ); !ok {
-		t.Error("Timestamp was not set")
+	connections, err := c.getOrInvalidate(idYYZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.entry(idYYZ); !ok {
+		t.Error("Entry was not set")
 	}
-	if !reflect.DeepEqual(c.cache.get(idYYZ), expectedCacheMap) {
-		t.Errorf("Expected %v,\ngot\n %v", expectedCacheMap, c.cache.get(idYYZ))
+	if !reflect.DeepEqual(connections, expectedCacheMap) {
+		t.Errorf("Expected %v,\ngot\n %v", expectedCacheMap, connections)
 	}
-	if !reflect.DeepEqual(c.infoCache.get(idYYZ), expectedInfoCacheMap) {
-		t.Errorf("Expected %v,\ngot\n %v", expectedInfoCacheMap, c.infoCache.get(idYYZ))
+	if info, _ := c.info(idYYZ); !reflect.DeepEqual(info, expectedInfoCacheMap) {
+		t.Errorf("Expected %v,\ngot\n %v", expectedInfoCacheMap, info)
 	}
-	c.cache = newIntCMap()
-	c.infoCache = newIntCMap()
-	c.connectionsTimeStamp = newIntCMap()
 
-	// Old timestamp
-	expectedCacheMap = map[int][]float64{idJFK: []float64{200.0}}
-	ts, _ := time.Parse(time.RFC822, time.RFC822)
-	c.connectionsTimeStamp.set(idYYZ, ts)
-	c.cache.set(idYYZ, make(map[int][]float64))
-	c.infoCache.set(idYYZ, make(map[int][]genConnectionInfo))
-	c.getOrInvalidate(idYYZ)
-	if c.connectionsTimeStamp.get(idYYZ).(time.Time).Equal(ts) {
-		t.Error("Timestamp did not change")
+	// Old entry
+	old := newCacheEntry()
+	old.loadedAt, _ = time.Parse(time.RFC822, time.RFC822)
+	c.setEntry(idYYZ, old)
+	connections, err = c.getOrInvalidate(idYYZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := c.entry(idYYZ); e == old {
+		t.Error("Entry did not change")
 	}
-	if !reflect.DeepEqual(c.cache.get(idYYZ), expectedCacheMap) {
-		t.Errorf("Expected %v,\ngot\n %v", expectedCacheMap, c.cache.get(idYYZ))
+	if !reflect.DeepEqual(connections, expectedCacheMap) {
+		t.Errorf("Expected %v,\ngot\n %v", expectedCacheMap, connections)
 	}
-	if !reflect.DeepEqual(c.infoCache.get(idYYZ), expectedInfoCacheMap) {
-		t.Errorf("Expected %v,\ngot\n %v", expectedInfoCacheMap, c.infoCache.get(idYYZ))
+	if info, _ := c.info(idYYZ); !reflect.DeepEqual(info, expectedInfoCacheMap) {
+		t.Errorf("Expected %v,\ngot\n %v", expectedInfoCacheMap, info)
 	}
 	cleanDb(session)
 }
@@ -325,10 +322,21 @@ func TestGetOrInvalidate(t *testing.T) {
 func TestSetNode(t *testing.T) {
 	c := newGenGraphCache(nil)
 	c.nodesCache = newIntCMap()
-	n := newNode("Airport", 0, map[string]interface{}{"code": "NYZ"})
+	n, err := newNode("Airport", 0, map[string]interface{}{"code": "NYZ"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	c.setNode(0, &n)
 	if _, ok := c.nodesCache.checkGet(0); !ok {
 		t.Error("Didn't store node correctly")
 	}
+	n, err = newNode("Airport", 0, map[string]interface{}{"code": "NYC", "latitude": 40.64, "longitude": -73.78})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.setNode(0, &n)
+	if loc, ok := c.nodesCache.get(0).(node).Location(); !ok || loc.Lat != 40.64 {
+		t.Errorf("Expected the node to be replaced, got %v", loc)
+	}
 }
 
@@ -0,0 +1,90 @@
+package graph
+
+import (
+	"time"
+
+	mongoEntity "github.com/jcasado94/connecc/mongo/entity"
+	mongoService "github.com/jcasado94/connecc/mongo/service"
+)
+
+// Heuristic estimates the price of the cheapest path from n to t, for trips travelling on travelDate, zero if
+// unknown. A* only finds the cheapest path if it never overestimates it.
+type Heuristic func(n, t int, travelDate time.Time) (float64, error)
+
+// AveragePrices are the average prices between nodes. drivers.MongoDriver is one.
+type AveragePrices interface {
+	GetAvgPrice(s, t int, travelDate, searchDate time.Time) (float64, error)
+}
+
+// PriceStats are the statistics of the prices between nodes. drivers.MongoDriver is one.
+type PriceStats interface {
+	GetPriceStats(s, t int) (mongoEntity.Average, error)
+}
+
+// ZeroHeuristic makes A* search as Dijkstra's algorithm, exploring more nodes but always finding the cheapest path.
+func ZeroHeuristic(n, t int, travelDate time.Time) (float64, error) {
+	return 0, nil
+}
+
+// NewAverageHeuristic estimates with the average price of the trips from n to t searched today. It's the most
+// informed estimate but often overestimates, so A* may miss the cheapest path.
+func NewAverageHeuristic(prices AveragePrices) Heuristic {
+	return func(n, t int, travelDate time.Time) (float64, error) {
+		if n == t {
+			return 0, nil
+		}
+		avg, err := prices.GetAvgPrice(n, t, travelDate, time.Now())
+		if err != nil {
+			return 0, priceStoreError(err)
+		}
+		return avg, nil
+	}
+}
+
+// NewMinimumHeuristic estimates with the cheapest price ever seen from n to t, or zero if none was. It only
+// overestimates when paths through other nodes beat every direct trip seen.
+func NewMinimumHeuristic(stats PriceStats) Heuristic {
+	return func(n, t int, travelDate time.Time) (float64, error) {
+		if n == t {
+			return 0, nil
+		}
+		avg, err := stats.GetPriceStats(n, t)
+		switch err.(type) {
+		case nil:
+		case mongoService.AvgDocumentNotFoundError, mongoService.AvgNotFoundError:
+			return 0, nil
+		default:
+			return 0, priceStoreError(err)
+		}
+		if avg.N == 0 {
+			return 0, nil
+		}
+		return avg.Min, nil
+	}
+}
+
+// priceStoreError tells the errors of the Mongo session apart from the ones the price services return, which are
+// passed through as they are.
+func priceStoreError(err error) error {
+	switch err.(type) {
+	case mongoService.AvgDocumentNotFoundError, mongoService.AvgNotFoundError, mongoService.AvgUpdateConflictError:
+		return err
+	}
+	return newDbUnavailableError(err)
+}
+
+// NewDistanceHeuristic estimates with the great-circle distance from n to t, located by locate, at the lowest fare
+// per kilometre of any provider, which never overestimates. Nodes not located are estimated at zero.
+func NewDistanceHeuristic(locate func(n int) (Coordinates, bool), farePerKm float64) Heuristic {
+	return func(n, t int, travelDate time.Time) (float64, error) {
+		cn, ok := locate(n)
+		if !ok {
+			return 0, nil
+		}
+		ct, ok := locate(t)
+		if !ok {
+			return 0, nil
+		}
+		return cn.DistanceKm(ct) * farePerKm, nil
+	}
+}
@@ -1,16 +1,51 @@
 package graph
 
+import "math"
+
+const earthRadiusKm = 6371.0
+
 const (
-	airportLabel = "Airport"
-	cityLabel    = "City"
+	airportLabel      = "Airport"
+	trainStationLabel = "TrainStation"
+	cityLabel         = "City"
 )
 
 type node interface {
 	Id() int
 	Equals(n node) bool
+	Location() (Coordinates, bool)
+}
+
+type Coordinates struct {
+	Lat, Lon float64
+}
+
+// DistanceKm returns the great-circle distance between c and c2.
+func (c Coordinates) DistanceKm(c2 Coordinates) float64 {
+	lat1, lat2 := c.Lat*math.Pi/180, c2.Lat*math.Pi/180
+	dLat, dLon := lat2-lat1, (c2.Lon-c.Lon)*math.Pi/180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// location holds the coordinates of a node, for the nodes stored with latitude and longitude properties.
+type location struct {
+	coordinates Coordinates
+	located     bool
+}
+
+func newLocation(params map[string]interface{}) location {
+	lat, okLat := params["latitude"].(float64)
+	lon, okLon := params["longitude"].(float64)
+	return location{Coordinates{lat, lon}, okLat && okLon}
+}
+
+func (l location) Location() (Coordinates, bool) {
+	return l.coordinates, l.located
 }
 
 type airport struct {
+	location
 	id   int
 	code string
 }
@@ -27,11 +62,34 @@ func (a *airport) Id() int {
 }
 
 func (a1 *airport) Equals(n node) bool {
-	a2 := n.(*airport)
-	return a1.id == a2.id && a1.code == a2.code
+	a2, ok := n.(*airport)
+	return ok && a1.id == a2.id && a1.code == a2.code
+}
+
+type trainStation struct {
+	location
+	id   int
+	code string
+}
+
+func newTrainStation(id int, code string) *trainStation {
+	return &trainStation{
+		id:   id,
+		code: code,
+	}
+}
+
+func (t *trainStation) Id() int {
+	return t.id
+}
+
+func (t1 *trainStation) Equals(n node) bool {
+	t2, ok := n.(*trainStation)
+	return ok && t1.id == t2.id && t1.code == t2.code
 }
 
 type city struct {
+	location
 	id   int
 	name string
 }
@@ -48,13 +106,35 @@ func (c *city) Id() int {
 }
 
 func (c1 *city) Equals(n node) bool {
-	c2 := n.(*city)
-	return c1.id == c2.id && c1.name == c2.name
+	c2, ok := n.(*city)
+	return ok && c1.id == c2.id && c1.name == c2.name
 }
 
-func newNode(label string, id int, params map[string]interface{}) node {
-	if label == airportLabel {
-		return newAirport(id, params["code"].(string))
+// newNode creates the node labelled label, failing if its properties lack its code or name.
+func newNode(label string, id int, params map[string]interface{}) (node, error) {
+	switch label {
+	case airportLabel:
+		code, ok := params["code"].(string)
+		if !ok {
+			return nil, newMissingPropertyError(label, id, "code")
+		}
+		a := newAirport(id, code)
+		a.location = newLocation(params)
+		return a, nil
+	case trainStationLabel:
+		code, ok := params["code"].(string)
+		if !ok {
+			return nil, newMissingPropertyError(label, id, "code")
+		}
+		t := newTrainStation(id, code)
+		t.location = newLocation(params)
+		return t, nil
+	}
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, newMissingPropertyError(label, id, "name")
 	}
-	return newCity(id, params["name"].(string))
+	c := newCity(id, name)
+	c.location = newLocation(params)
+	return c, nil
 }
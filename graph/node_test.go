@@ -0,0 +1,18 @@
+package graph
+
+import "testing"
+
+func TestNodeEquals(t *testing.T) {
+	nodes := []node{newAirport(1, "BOS"), newTrainStation(1, "BOS"), newCity(1, "BOS")}
+	for i, n := range nodes {
+		for j, n2 := range nodes {
+			// Nodes of different types never equal, even sharing their id and code.
+			if equals := n.Equals(n2); equals != (i == j) {
+				t.Errorf("Expected %T.Equals(%T) to be %v, got %v", n, n2, i == j, equals)
+			}
+		}
+	}
+	if newAirport(1, "BOS").Equals(newAirport(1, "DEN")) {
+		t.Error("Expected airports of different codes to differ")
+	}
+}
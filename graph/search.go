@@ -0,0 +1,107 @@
+package graph
+
+import "container/heap"
+
+// Graph is what Search finds the cheapest path in. genGraph is one.
+type Graph interface {
+	S() int
+	T() int
+	// Connections returns the prices of the ways of reaching every neighbour of n.
+	Connections(n int) (map[int][]float64, error)
+	// FValue estimates the price of the cheapest path from n to T.
+	FValue(n int) (float64, error)
+}
+
+type Path struct {
+	Nodes []int
+	Price float64
+}
+
+// Search finds the cheapest path from S to T with A*, guided by FValue. The path is only sure to be the cheapest if
+// FValue never overestimates. It returns false if T can't be reached, and the first error of g, if any.
+func Search(g Graph) (Path, bool, error) {
+	s, t := g.S(), g.T()
+	f, err := g.FValue(s)
+	if err != nil {
+		return Path{}, false, err
+	}
+	prices := map[int]float64{s: 0}
+	prev := make(map[int]int)
+	closed := make(map[int]bool)
+	open := &searchQueue{{n: s, f: f}}
+	for open.Len() > 0 {
+		item := heap.Pop(open).(searchItem)
+		n := item.n
+		if closed[n] || item.price > prices[n] {
+			continue
+		}
+		if n == t {
+			return newPath(prev, s, t, prices[t]), true, nil
+		}
+		closed[n] = true
+		connections, err := g.Connections(n)
+		if err != nil {
+			return Path{}, false, err
+		}
+		for m, mPrices := range connections {
+			if len(mPrices) == 0 {
+				continue
+			}
+			price := prices[n] + minPrice(mPrices)
+			if p, seen := prices[m]; seen && p <= price {
+				continue
+			}
+			// A cheaper way to a closed node reopens it, as heuristics aren't required to be consistent.
+			delete(closed, m)
+			prices[m], prev[m] = price, n
+			h, err := g.FValue(m)
+			if err != nil {
+				return Path{}, false, err
+			}
+			heap.Push(open, searchItem{n: m, price: price, f: price + h})
+		}
+	}
+	return Path{}, false, nil
+}
+
+func newPath(prev map[int]int, s, t int, price float64) Path {
+	nodes := []int{t}
+	for n := t; n != s; {
+		n = prev[n]
+		nodes = append(nodes, n)
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	return Path{nodes, price}
+}
+
+func minPrice(prices []float64) float64 {
+	min := prices[0]
+	for _, p := range prices[1:] {
+		if p < min {
+			min = p
+		}
+	}
+	return min
+}
+
+type searchItem struct {
+	n int
+	// price is the price of the path to n found so far, and f the estimate of the path to T through it.
+	price, f float64
+}
+
+// searchQueue pops the node with the lowest f first.
+type searchQueue []searchItem
+
+func (q searchQueue) Len() int            { return len(q) }
+func (q searchQueue) Less(i, j int) bool  { return q[i].f < q[j].f }
+func (q searchQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *searchQueue) Push(x interface{}) { *q = append(*q, x.(searchItem)) }
+func (q *searchQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
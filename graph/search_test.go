@@ -0,0 +1,223 @@
+package graph
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	mongoEntity "github.com/jcasado94/connecc/mongo/entity"
+	mongoModel "github.com/jcasado94/connecc/mongo/model"
+	mongoService "github.com/jcasado94/connecc/mongo/service"
+)
+
+const (
+	idBOS = iota
+	idNYC
+	idWAS
+	idCHI
+	idDEN
+)
+
+var testCoordinates = map[int]Coordinates{
+	idBOS: {42.36, -71.01},
+	idNYC: {40.64, -73.78},
+	idWAS: {38.85, -77.04},
+	idCHI: {41.97, -87.90},
+	idDEN: {39.86, -104.67},
+}
+
+// memGraph is an in-memory Graph estimating with h. The cheapest path from BOS to DEN goes through NYC and WAS for
+// 370, while the one through CHI costs 400 and the direct one 450.
+type memGraph struct {
+	s, t  int
+	edges map[int]map[int][]float64
+	h     Heuristic
+}
+
+func newMemGraph(h Heuristic) *memGraph {
+	return &memGraph{
+		s: idBOS,
+		t: idDEN,
+		edges: map[int]map[int][]float64{
+			idBOS: {idCHI: {250, 280}, idNYC: {40}, idDEN: {450}},
+			idNYC: {idWAS: {30}},
+			idWAS: {idDEN: {300, 320}},
+			idCHI: {idDEN: {150}},
+		},
+		h: h,
+	}
+}
+
+func (g *memGraph) S() int { return g.s }
+func (g *memGraph) T() int { return g.t }
+
+func (g *memGraph) Connections(n int) (map[int][]float64, error) {
+	if _, ok := testCoordinates[n]; !ok {
+		return nil, newNodeNotFoundError(n)
+	}
+	return g.edges[n], nil
+}
+
+func (g *memGraph) FValue(n int) (float64, error) {
+	return g.h(n, g.t, time.Time{})
+}
+
+type fakePrices map[int]float64
+
+func (p fakePrices) GetAvgPrice(s, t int, travelDate, searchDate time.Time) (float64, error) {
+	return p[s], nil
+}
+
+func (p fakePrices) GetPriceStats(s, t int) (mongoEntity.Average, error) {
+	min, ok := p[s]
+	if !ok {
+		return mongoEntity.Average{}, mongoService.AvgNotFoundError{}
+	}
+	return mongoEntity.Average{Avg: min, N: 1, Min: min, Max: min}, nil
+}
+
+// seasonalPrices looks the averages up in the documents of every node, as the average price service does.
+type seasonalPrices map[int]*mongoModel.AveragePriceModel
+
+func (p seasonalPrices) GetAvgPrice(s, t int, travelDate, searchDate time.Time) (float64, error) {
+	avg, ok := p[s].AverageOn(fmt.Sprint(t), travelDate, searchDate)
+	if !ok {
+		return 0, mongoService.AvgNotFoundError{}
+	}
+	return avg.Avg, nil
+}
+
+func TestFValueTravelDate(t *testing.T) {
+	travel := time.Now().AddDate(0, 0, 10)
+	weekday := mongoEntity.BucketKeys(travel, travel)[2]
+	prices := seasonalPrices{idBOS: &mongoModel.AveragePriceModel{
+		Averages: map[string]mongoModel.Average{fmt.Sprint(idDEN): {Avg: 300, N: 50}},
+		Seasonal: map[string]map[string]mongoModel.Average{
+			fmt.Sprint(idDEN): {weekday: {Avg: 100, N: mongoEntity.MinBucketPrices}},
+		},
+	}}
+	tests := []struct {
+		travel   time.Time
+		expected float64
+	}{
+		{travel, 100},
+		{time.Time{}, 300},
+	}
+	for _, test := range tests {
+		g := &genGraph{s: idBOS, t: idDEN, date: test.travel, heuristic: NewAverageHeuristic(prices)}
+		if f, err := g.FValue(idBOS); err != nil || f != test.expected {
+			t.Errorf("Expected %v travelling on %v, got %v, %v", test.expected, test.travel, f, err)
+		}
+	}
+}
+
+// failingPrices fails every lookup with err.
+type failingPrices struct {
+	err error
+}
+
+func (p failingPrices) GetAvgPrice(s, t int, travelDate, searchDate time.Time) (float64, error) {
+	return 0, p.err
+}
+
+func TestFValueErrors(t *testing.T) {
+	isDbUnavailable := func(err error) bool {
+		_, ok := err.(DbUnavailableError)
+		return ok
+	}
+	tests := []struct {
+		name          string
+		h             Heuristic
+		dbUnavailable bool
+	}{
+		{"session", NewAverageHeuristic(failingPrices{fmt.Errorf("no reachable servers")}), true},
+		{"conflict", NewAverageHeuristic(failingPrices{mongoService.AvgUpdateConflictError{}}), false},
+		{"custom", func(n, t int, travelDate time.Time) (float64, error) { return 0, fmt.Errorf("no estimate") }, false},
+	}
+	for _, test := range tests {
+		g := &genGraph{s: idBOS, t: idDEN, heuristic: test.h}
+		_, err := g.FValue(idBOS)
+		if err == nil || isDbUnavailable(err) != test.dbUnavailable {
+			t.Errorf("%s: expected a DbUnavailableError %v, got %v", test.name, test.dbUnavailable, err)
+		}
+	}
+}
+
+func TestSearchHeuristics(t *testing.T) {
+	locate := func(n int) (Coordinates, bool) {
+		c, ok := testCoordinates[n]
+		return c, ok
+	}
+	cheapest := Path{Nodes: []int{idBOS, idNYC, idWAS, idDEN}, Price: 370}
+	tests := []struct {
+		name       string
+		h          Heuristic
+		expected   Path
+		admissible bool
+	}{
+		{"zero", ZeroHeuristic, cheapest, true},
+		// Averages above the cheapest price through NYC steer the search through CHI.
+		{"average", NewAverageHeuristic(fakePrices{idBOS: 420, idNYC: 600, idWAS: 500, idCHI: 150}), Path{Nodes: []int{idBOS, idCHI, idDEN}, Price: 400}, false},
+		{"minimum", NewMinimumHeuristic(fakePrices{idBOS: 370, idNYC: 330, idWAS: 300}), cheapest, true},
+		{"distance", NewDistanceHeuristic(locate, 0.05), cheapest, true},
+	}
+	for _, test := range tests {
+		path, ok, err := Search(newMemGraph(test.h))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: expected a path", test.name)
+			continue
+		}
+		if !reflect.DeepEqual(path, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, path)
+		}
+		if !test.admissible {
+			continue
+		}
+		for n := idBOS; n < idDEN; n++ {
+			estimate, _ := test.h(n, idDEN, time.Time{})
+			g := newMemGraph(ZeroHeuristic)
+			g.s = n
+			if path, _, _ := Search(g); estimate > path.Price {
+				t.Errorf("%s: estimated %v from %d, more than the cheapest %v", test.name, estimate, n, path.Price)
+			}
+		}
+	}
+}
+
+func TestSearchUnreachable(t *testing.T) {
+	g := newMemGraph(ZeroHeuristic)
+	g.s = idDEN
+	g.t = idBOS
+	if path, ok, err := Search(g); ok || err != nil {
+		t.Errorf("Expected no path, got %v, %v", path, err)
+	}
+	g.t = idDEN
+	if path, ok, err := Search(g); !ok || err != nil || !reflect.DeepEqual(path.Nodes, []int{idDEN}) || path.Price != 0 {
+		t.Errorf("Expected an empty path, got %v, %v", path, err)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	g := newMemGraph(ZeroHeuristic)
+	g.edges[idNYC] = map[int][]float64{idWAS: {30}, 42: {1}}
+	g.edges[idCHI] = map[int][]float64{42: {1}}
+	if _, _, err := Search(g); !reflect.DeepEqual(err, newNodeNotFoundError(42)) {
+		t.Errorf("Expected the missing node to be reported, got %v", err)
+	}
+
+	unavailable := newDbUnavailableError(fmt.Errorf("connection refused"))
+	g = newMemGraph(func(n, t int, travelDate time.Time) (float64, error) {
+		if n == idWAS {
+			return 0, unavailable
+		}
+		return 0, nil
+	})
+	if _, _, err := Search(g); err != unavailable {
+		t.Errorf("Expected %v, got %v", unavailable, err)
+	}
+}
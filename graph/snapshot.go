@@ -0,0 +1,73 @@
+package graph
+
+import "sync"
+
+// Snapshot is the view of a genGraph a single search works on. The first time it's asked for the connections of a
+// node it pins their version, so that the search keeps seeing the same prices, and providers, even if the graph
+// refreshes the node meanwhile.
+type Snapshot struct {
+	g       *genGraph
+	mu      sync.Mutex
+	entries map[int]*cacheEntry
+}
+
+func (g *genGraph) Snapshot() *Snapshot {
+	return &Snapshot{
+		g:       g,
+		entries: make(map[int]*cacheEntry),
+	}
+}
+
+func (s *Snapshot) S() int {
+	return s.g.S()
+}
+
+func (s *Snapshot) T() int {
+	return s.g.T()
+}
+
+func (s *Snapshot) entry(n int) (*cacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[n]; ok {
+		return e, nil
+	}
+	e, err := s.g.cache.getEntry(n)
+	if err != nil {
+		return nil, err
+	}
+	s.entries[n] = e
+	return e, nil
+}
+
+func (s *Snapshot) Connections(n int) (map[int][]float64, error) {
+	e, err := s.entry(n)
+	if err != nil {
+		return nil, err
+	}
+	return e.connections, nil
+}
+
+func (s *Snapshot) FValue(n int) (float64, error) {
+	return s.g.FValue(n)
+}
+
+// Providers returns the names of the providers of the Gen connections from n to m, in the same order as their prices
+// in Connections(n)[m].
+func (s *Snapshot) Providers(n, m int) ([]string, error) {
+	e, err := s.entry(n)
+	if err != nil {
+		return nil, err
+	}
+	return s.g.providerNames(e.info[m]), nil
+}
+
+// Version returns the version of the connections of n the snapshot pinned, or -1 if it hasn't loaded them.
+func (s *Snapshot) Version(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[n]; ok {
+		return e.version
+	}
+	return -1
+}
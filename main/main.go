@@ -2,12 +2,25 @@ package main
 
 import (
 	"fmt"
+	"log"
 
+	"github.com/jcasado94/connecc/providers"
 	"github.com/jcasado94/connecc/scraping"
 )
 
 func main() {
-	scraper := scraping.NewSpiritScraper()
+	catalogue, err := providers.Load("providers.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	spirit, err := catalogue.ByName("spirit")
+	if err != nil {
+		log.Fatal(err)
+	}
+	scraper, err := scraping.NewProviderScraper(spirit)
+	if err != nil {
+		log.Fatal(err)
+	}
 	trips, _ := scraper.GetTrips("BOS", "DEN", 2, 9, 2019, 1, 0, 0)
 	for _, trip := range trips {
 		fmt.Println(trip)
@@ -0,0 +1,226 @@
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const defaultExponent = 2
+
+type currency struct {
+	symbol   string
+	exponent int
+}
+
+var currencies = map[string]currency{
+	"USD": {"$", 2},
+	"CAD": {"$", 2},
+	"MXN": {"$", 2},
+	"EUR": {"€", 2},
+	"GBP": {"£", 2},
+	"JPY": {"¥", 0},
+}
+
+var symbols = map[string]string{
+	"US$": "USD",
+	"C$":  "CAD",
+	"CA$": "CAD",
+	"MX$": "MXN",
+	"€":   "EUR",
+	"£":   "GBP",
+	"¥":   "JPY",
+}
+
+// Money is an amount of an ISO 4217 currency, kept in the currency's minor units (cents for USD) so that adding
+// prices up doesn't accumulate rounding errors.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// New creates a Money of amount minor units of currency.
+func New(amount int64, currency string) Money {
+	return Money{
+		Amount:   amount,
+		Currency: currency,
+	}
+}
+
+// FromFloat creates a Money from an amount in major units, rounded to the currency's minor units.
+func FromFloat(amount float64, currency string) Money {
+	return New(int64(math.Round(amount*math.Pow10(exponent(currency)))), currency)
+}
+
+// Known tells whether code is one of the currencies this package knows the symbol and minor units of.
+func Known(code string) bool {
+	_, ok := currencies[code]
+	return ok
+}
+
+func exponent(code string) int {
+	if c, ok := currencies[code]; ok {
+		return c.exponent
+	}
+	return defaultExponent
+}
+
+// Float64 returns the amount in major units.
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / math.Pow10(exponent(m.Currency))
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add sums two amounts of the same currency.
+func (m Money) Add(m2 Money) (Money, error) {
+	if m.Currency != m2.Currency {
+		return Money{}, fmt.Errorf("Can't add %s to %s", m2, m)
+	}
+	return New(m.Amount+m2.Amount, m.Currency), nil
+}
+
+// Times multiplies the amount by n, as when paying the same price for n travellers.
+func (m Money) Times(n int) Money {
+	return New(m.Amount*int64(n), m.Currency)
+}
+
+// Cmp returns -1, 0 or 1 depending on whether m is less than, equal to or greater than m2, which must be of the same currency.
+func (m Money) Cmp(m2 Money) (int, error) {
+	if m.Currency != m2.Currency {
+		return 0, fmt.Errorf("Can't compare %s to %s", m2, m)
+	}
+	switch {
+	case m.Amount < m2.Amount:
+		return -1, nil
+	case m.Amount > m2.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%s %.*f", m.Currency, exponent(m.Currency), m.Float64())
+}
+
+// Parse reads a price as shown by providers, such as "$1,234.56", "1.234,56 €", "£12" or "CAD 35.00".
+// The currency is taken from the symbol or ISO code in s, defaulting to defaultCurrency when there is none.
+// A bare "$" is read as defaultCurrency when that is a dollar currency, and as USD otherwise.
+func Parse(s, defaultCurrency string) (Money, error) {
+	var marker, number strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case unicode.IsDigit(r) || r == '.' || r == ',' || r == '-':
+			number.WriteRune(r)
+		case unicode.IsSpace(r) || r == '\'':
+		default:
+			marker.WriteRune(r)
+		}
+	}
+
+	code, err := currencyFromMarker(marker.String(), defaultCurrency)
+	if err != nil {
+		return Money{}, fmt.Errorf("Couldn't parse price %q: %v", s, err)
+	}
+	amount, err := parseAmount(number.String(), exponent(code))
+	if err != nil {
+		return Money{}, fmt.Errorf("Couldn't parse price %q: %v", s, err)
+	}
+	return New(amount, code), nil
+}
+
+func currencyFromMarker(marker, defaultCurrency string) (string, error) {
+	switch {
+	case marker == "":
+		return defaultCurrency, nil
+	case marker == "$":
+		if c, ok := currencies[defaultCurrency]; ok && c.symbol == "$" {
+			return defaultCurrency, nil
+		}
+		return "USD", nil
+	}
+	if code, ok := symbols[marker]; ok {
+		return code, nil
+	}
+	if code := strings.ToUpper(marker); len(code) == 3 {
+		if _, ok := currencies[code]; ok {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("unknown currency %q", marker)
+}
+
+// parseAmount converts a number with optional thousands separators into minor units. The last '.' or ',' is taken
+// as the decimal separator unless exactly three digits follow it, in which case it separates thousands.
+func parseAmount(number string, exp int) (int64, error) {
+	negative := strings.HasPrefix(number, "-")
+	number = strings.TrimPrefix(number, "-")
+	if number == "" {
+		return 0, fmt.Errorf("no amount")
+	}
+
+	whole, frac := number, ""
+	if i := strings.LastIndexAny(number, ".,"); i >= 0 && len(number)-i-1 != 3 {
+		whole, frac = number[:i], number[i+1:]
+	}
+	whole = strings.NewReplacer(".", "", ",", "").Replace(whole)
+	if whole == "" {
+		whole = "0"
+	}
+	if len(frac) > exp {
+		return 0, fmt.Errorf("more decimals than the currency allows")
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// Converter converts amounts between currencies.
+type Converter interface {
+	Convert(m Money, currency string) (Money, error)
+}
+
+// RateTable is a Converter backed by fixed exchange rates against a base currency.
+type RateTable struct {
+	base  string
+	rates map[string]float64
+}
+
+// NewRateTable creates a RateTable where rates holds how many units of each currency one unit of base buys.
+func NewRateTable(base string, rates map[string]float64) *RateTable {
+	rt := &RateTable{
+		base:  base,
+		rates: make(map[string]float64),
+	}
+	for code, rate := range rates {
+		rt.rates[code] = rate
+	}
+	rt.rates[base] = 1.0
+	return rt
+}
+
+func (rt *RateTable) Convert(m Money, currency string) (Money, error) {
+	if m.Currency == currency {
+		return m, nil
+	}
+	from, ok := rt.rates[m.Currency]
+	if !ok {
+		return Money{}, fmt.Errorf("No exchange rate for %s", m.Currency)
+	}
+	to, ok := rt.rates[currency]
+	if !ok {
+		return Money{}, fmt.Errorf("No exchange rate for %s", currency)
+	}
+	return FromFloat(m.Float64()/from*to, currency), nil
+}
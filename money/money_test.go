@@ -0,0 +1,81 @@
+package money
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		s, defaultCurrency string
+		expected           Money
+	}{
+		{"$158.18", "USD", New(15818, "USD")},
+		{"\n\t$1,234.56 ", "USD", New(123456, "USD")},
+		{"$35", "CAD", New(3500, "CAD")},
+		{"$35", "GBP", New(3500, "USD")},
+		{"C$35.5", "USD", New(3550, "CAD")},
+		{"1.234,56 €", "USD", New(123456, "EUR")},
+		{"£12", "USD", New(1200, "GBP")},
+		{"GBP 7.50", "USD", New(750, "GBP")},
+		{"1,234", "USD", New(123400, "USD")},
+		{"¥1,200", "USD", New(1200, "JPY")},
+		{"99.00", "EUR", New(9900, "EUR")},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Parse %q", tc.s), func(t *testing.T) {
+			m, err := Parse(tc.s, tc.defaultCurrency)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, m)
+			}
+		})
+	}
+
+	for _, s := range []string{"", "$", "12.50 XYZ", "¥1.50"} {
+		if m, err := Parse(s, "USD"); err == nil {
+			t.Errorf("Expected an error parsing %q, got %v", s, m)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	sum, err := New(15818, "USD").Add(FromFloat(0.1, "USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != New(15828, "USD") {
+		t.Errorf("Expected USD 158.28, got %v", sum)
+	}
+	if _, err := New(100, "USD").Add(New(100, "EUR")); err == nil {
+		t.Error("Expected an error adding different currencies")
+	}
+}
+
+func TestRateTable(t *testing.T) {
+	rt := NewRateTable("USD", map[string]float64{"EUR": 0.9, "GBP": 0.8})
+	testCases := []struct {
+		m        Money
+		currency string
+		expected Money
+	}{
+		{New(10000, "USD"), "USD", New(10000, "USD")},
+		{New(10000, "USD"), "EUR", New(9000, "EUR")},
+		{New(9000, "EUR"), "USD", New(10000, "USD")},
+		{New(9000, "EUR"), "GBP", New(8000, "GBP")},
+	}
+	for _, tc := range testCases {
+		converted, err := rt.Convert(tc.m, tc.currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if converted != tc.expected {
+			t.Errorf("Converting %v to %s: expected %v, got %v", tc.m, tc.currency, tc.expected, converted)
+		}
+	}
+	if _, err := rt.Convert(New(100, "MXN"), "USD"); err == nil {
+		t.Error("Expected an error converting a currency without rate")
+	}
+}
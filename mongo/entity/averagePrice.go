@@ -1,16 +1,88 @@
 package entity
 
-import "strconv"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jcasado94/connecc/stats"
+)
+
+// MinBucketPrices is how many prices a bucket needs before its average is preferred over a coarser one.
+const MinBucketPrices = 5
+
+// leadTimeBuckets are the lower bounds, in days before travelling, of the lead time buckets.
+var leadTimeBuckets = []int{0, 3, 7, 14, 30, 60}
 
 type AveragePrice struct {
 	ID       string             `json:"id"`
 	NodeId   int                `json:"nodeId"`
 	Averages map[string]Average `json:"averages"`
+	// Seasonal holds, for every target, the averages of each bucket of lead time and weekday, keyed by BucketKeys.
+	Seasonal map[string]map[string]Average `json:"seasonal"`
+}
+
+// BucketKeys returns the keys of the buckets the price of a trip travelling on travelDate, searched on searchDate,
+// falls into, from the most specific to the coarsest: by lead time and weekday, as in "lead7-13/wed", by lead time
+// and by weekday.
+func BucketKeys(travelDate, searchDate time.Time) []string {
+	days := int(dateOf(travelDate).Sub(dateOf(searchDate)).Hours() / 24)
+	lead := ""
+	for i, from := range leadTimeBuckets {
+		if days < from {
+			break
+		}
+		if i == len(leadTimeBuckets)-1 {
+			lead = fmt.Sprintf("lead%d+", from)
+		} else {
+			lead = fmt.Sprintf("lead%d-%d", from, leadTimeBuckets[i+1]-1)
+		}
+	}
+	weekday := strings.ToLower(travelDate.Weekday().String()[:3])
+	if lead == "" {
+		return []string{weekday}
+	}
+	return []string{lead + "/" + weekday, lead, weekday}
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
 }
 
 type Average struct {
 	Avg float64 `json:"avg"`
 	N   int     `json:"n"`
+	// Min, Max and M2, the sum of the squared differences from Avg, complete the stats.Summary of the prices.
+	Min    float64      `json:"min"`
+	Max    float64      `json:"max"`
+	M2     float64      `json:"m2"`
+	Sketch stats.Sketch `json:"sketch"`
+}
+
+func (a Average) Summary() stats.Summary {
+	return stats.Summary{N: a.N, Mean: a.Avg, M2: a.M2, Min: a.Min, Max: a.Max}
+}
+
+// Add adds a price to the average and its statistics. Averages stored before Min, Max and M2 were have no sketch of
+// their prices, so they're assumed to have been all at the average.
+func (a *Average) Add(price float64) {
+	summary := a.Summary()
+	if a.N > 0 && a.Sketch.Count() == 0 {
+		summary.Min, summary.Max = a.Avg, a.Avg
+	}
+	summary.Add(price)
+	a.Avg, a.N, a.M2, a.Min, a.Max = summary.Mean, summary.N, summary.M2, summary.Min, summary.Max
+	a.Sketch.Add(price)
+}
+
+func (a Average) Variance() float64 {
+	return a.Summary().Variance()
+}
+
+// Quantile returns an estimate of the q-quantile of the prices, as the median for 0.5.
+func (a Average) Quantile(q float64) float64 {
+	return a.Sketch.Quantile(q)
 }
 
 func NewAveragePrice(s, t int) (avgPrice AveragePrice, price float64) {
@@ -27,5 +99,5 @@ func NewAveragePrice(s, t int) (avgPrice AveragePrice, price float64) {
 }
 
 type AveragePriceService interface {
-	GetAverage(s, t int) (float64, error)
+	GetAverage(s, t int, travelDate, searchDate time.Time) (float64, error)
 }
@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBucketKeys(t *testing.T) {
+	search := time.Date(2019, time.Month(9), 8, 22, 30, 0, 0, time.UTC)
+	tests := []struct {
+		travel   time.Time
+		expected []string
+	}{
+		{time.Date(2019, time.Month(9), 8, 0, 0, 0, 0, time.UTC), []string{"lead0-2/sun", "lead0-2", "sun"}},
+		{time.Date(2019, time.Month(9), 18, 0, 0, 0, 0, time.UTC), []string{"lead7-13/wed", "lead7-13", "wed"}},
+		{time.Date(2019, time.Month(12), 24, 0, 0, 0, 0, time.UTC), []string{"lead60+/tue", "lead60+", "tue"}},
+		{time.Date(2019, time.Month(9), 7, 0, 0, 0, 0, time.UTC), []string{"sat"}},
+	}
+	for _, test := range tests {
+		if keys := BucketKeys(test.travel, search); !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("Expected %v for %v, got %v", test.expected, test.travel, keys)
+		}
+	}
+}
+
+func TestAverageAdd(t *testing.T) {
+	legacy := Average{Avg: 100, N: 4}
+	legacy.Add(50)
+	if legacy.N != 5 || legacy.Avg != 90 || legacy.Min != 50 || legacy.Max != 100 {
+		t.Errorf("Expected 5 prices from 50 to 100 averaging 90, got %+v", legacy)
+	}
+	var avg Average
+	for _, p := range []float64{10, 20, 30} {
+		avg.Add(p)
+	}
+	if avg.Variance() != 100 || avg.Quantile(0.5) < 19.8 || avg.Quantile(0.5) > 20.2 {
+		t.Errorf("Expected variance 100 and median 20, got %v and %v", avg.Variance(), avg.Quantile(0.5))
+	}
+}
@@ -0,0 +1,10 @@
+package entity
+
+import "time"
+
+// CachedTrips holds the encoded trips found by a search, until ExpiresAt.
+type CachedTrips struct {
+	Key       string    `json:"key"`
+	Trips     []byte    `json:"trips"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+// PriceObservation is a fare found by a search from the node NodeId to TargetId, travelling on TravelDate.
+type PriceObservation struct {
+	NodeId     int       `json:"nodeId"`
+	TargetId   int       `json:"targetId"`
+	Provider   int       `json:"provider"`
+	TravelDate time.Time `json:"travelDate"`
+	ScrapedAt  time.Time `json:"scrapedAt"`
+	// TripKey tells apart the fares of the different trips of a search.
+	TripKey  string `json:"tripKey"`
+	FareType string `json:"fareType"`
+	// Price is the price for a single adult.
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency"`
+}
+
+// PriceRollup sums up the observations of a fare type of a provider from NodeId to TargetId, travelling on
+// TravelDate, once they're too old to be kept one by one.
+type PriceRollup struct {
+	NodeId     int       `json:"nodeId"`
+	TargetId   int       `json:"targetId"`
+	Provider   int       `json:"provider"`
+	TravelDate time.Time `json:"travelDate"`
+	FareType   string    `json:"fareType"`
+	Currency   string    `json:"currency"`
+	Min        float64   `json:"min"`
+	Max        float64   `json:"max"`
+	Sum        float64   `json:"sum"`
+	N          int       `json:"n"`
+	// FirstScrapedAt and LastScrapedAt bound the scrape times of the observations summed up.
+	FirstScrapedAt time.Time `json:"firstScrapedAt"`
+	LastScrapedAt  time.Time `json:"lastScrapedAt"`
+}
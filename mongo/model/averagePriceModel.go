@@ -1,33 +1,76 @@
 package model
 
 import (
+	"time"
+
 	"github.com/jcasado94/connecc/mongo/entity"
+	"github.com/jcasado94/connecc/stats"
 	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 type AveragePriceModel struct {
-	ID       bson.ObjectId      `bson:"_id,omitempty"`
-	NodeId   int                `bson:"nodeId"`
-	Averages map[string]Average `bson:"averages"`
+	ID       bson.ObjectId                 `bson:"_id,omitempty"`
+	NodeId   int                           `bson:"nodeId"`
+	Averages map[string]Average            `bson:"averages"`
+	Seasonal map[string]map[string]Average `bson:"seasonal,omitempty"`
+	// Version is increased by every update, so that writers can tell whether the document changed since they read it.
+	// Documents written before versioning have none, read as 0.
+	Version int `bson:"version"`
+	// Updates holds the ids of the last updates added to the average of each target.
+	Updates map[string][]string `bson:"updates,omitempty"`
 }
 
+// averageUpdates is how many of the last updates added to the average of a target a document remembers.
+const averageUpdates = 128
+
 type Average struct {
-	Avg float64 `bson:"avg"`
-	N   int     `bson:"n"`
+	Avg    float64      `bson:"avg"`
+	N      int          `bson:"n"`
+	Min    float64      `bson:"min"`
+	Max    float64      `bson:"max"`
+	M2     float64      `bson:"m2"`
+	Sketch stats.Sketch `bson:"sketch"`
+}
+
+func NewAverage(a entity.Average) Average {
+	return Average{
+		Avg:    a.Avg,
+		N:      a.N,
+		Min:    a.Min,
+		Max:    a.Max,
+		M2:     a.M2,
+		Sketch: a.Sketch,
+	}
+}
+
+func (a Average) ToEntity() entity.Average {
+	return entity.Average{
+		Avg:    a.Avg,
+		N:      a.N,
+		Min:    a.Min,
+		Max:    a.Max,
+		M2:     a.M2,
+		Sketch: a.Sketch,
+	}
 }
 
 func NewAveragePriceModel(ap *entity.AveragePrice) *AveragePriceModel {
 	averages := make(map[string]Average)
 	for key, value := range ap.Averages {
-		averages[key] = Average{
-			Avg: value.Avg,
-			N:   value.N,
+		averages[key] = NewAverage(value)
+	}
+	seasonal := make(map[string]map[string]Average)
+	for t, buckets := range ap.Seasonal {
+		seasonal[t] = make(map[string]Average)
+		for key, value := range buckets {
+			seasonal[t][key] = NewAverage(value)
 		}
 	}
 	return &AveragePriceModel{
 		NodeId:   ap.NodeId,
 		Averages: averages,
+		Seasonal: seasonal,
 	}
 }
 
@@ -39,6 +82,95 @@ func (apm *AveragePriceModel) AddAverage(t string, price float64, n int) {
 	apm.Averages[t] = avg
 }
 
+func (apm *AveragePriceModel) SetAverage(t string, avg entity.Average) {
+	apm.Averages[t] = NewAverage(avg)
+}
+
+// AddSeasonal adds price to the averages of target t in the buckets of keys.
+func (apm *AveragePriceModel) AddSeasonal(t string, keys []string, price float64) {
+	if apm.Seasonal == nil {
+		apm.Seasonal = make(map[string]map[string]Average)
+	}
+	if apm.Seasonal[t] == nil {
+		apm.Seasonal[t] = make(map[string]Average)
+	}
+	for _, key := range keys {
+		avg := apm.Seasonal[t][key].ToEntity()
+		avg.Add(price)
+		apm.Seasonal[t][key] = NewAverage(avg)
+	}
+}
+
+// Updated tells whether the update of id was already added to the average of target t. Updates with no id never were.
+func (apm *AveragePriceModel) Updated(t, id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, updated := range apm.Updates[t] {
+		if updated == id {
+			return true
+		}
+	}
+	return false
+}
+
+// RememberUpdate remembers the update of id as added to the average of target t, forgetting the oldest beyond
+// averageUpdates.
+func (apm *AveragePriceModel) RememberUpdate(t, id string) {
+	if id == "" {
+		return
+	}
+	if apm.Updates == nil {
+		apm.Updates = make(map[string][]string)
+	}
+	updates := append(apm.Updates[t], id)
+	if len(updates) > averageUpdates {
+		updates = updates[len(updates)-averageUpdates:]
+	}
+	apm.Updates[t] = updates
+}
+
+// SeasonalAverage returns the average of target t in the first bucket of keys holding entity.MinBucketPrices prices.
+func (apm *AveragePriceModel) SeasonalAverage(t string, keys []string) (Average, bool) {
+	for _, key := range keys {
+		if avg, ok := apm.Seasonal[t][key]; ok && avg.N >= entity.MinBucketPrices {
+			return avg, true
+		}
+	}
+	return Average{}, false
+}
+
+// AverageOn returns the average of target t for the trips travelling on travelDate, searched on searchDate: the one of
+// the finest bucket holding entity.MinBucketPrices prices, or else the average of every trip, which is also the one
+// returned for a zero travelDate.
+func (apm *AveragePriceModel) AverageOn(t string, travelDate, searchDate time.Time) (Average, bool) {
+	if !travelDate.IsZero() {
+		if avg, ok := apm.SeasonalAverage(t, entity.BucketKeys(travelDate, searchDate)); ok {
+			return avg, true
+		}
+	}
+	avg, ok := apm.Averages[t]
+	return avg, ok
+}
+
+// AveragePriceModelNodeIndex makes a single document per node. Its creation fails on collections already holding
+// several documents of a node, which must be merged by hand first.
+func AveragePriceModelNodeIndex() mgo.Index {
+	return mgo.Index{
+		Key:        []string{"nodeId"},
+		Unique:     true,
+		Background: true,
+	}
+}
+
+// VersionQuery matches the document of node s if it's still at version.
+func VersionQuery(s, version int) bson.M {
+	if version == 0 {
+		return bson.M{"nodeId": s, "version": bson.M{"$in": []interface{}{0, nil}}}
+	}
+	return bson.M{"nodeId": s, "version": version}
+}
+
 func AveragePriceModelIndex() mgo.Index {
 	return mgo.Index{
 		Key:        []string{"ID"},
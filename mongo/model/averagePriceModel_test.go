@@ -0,0 +1,70 @@
+package model
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jcasado94/connecc/mongo/entity"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestAverageOn(t *testing.T) {
+	search := time.Date(2019, time.Month(9), 8, 22, 30, 0, 0, time.UTC)
+	// Wednesday, 10 days ahead: "lead7-13/wed", "lead7-13", "wed".
+	travel := time.Date(2019, time.Month(9), 18, 0, 0, 0, 0, time.UTC)
+	full, sparse := entity.MinBucketPrices, entity.MinBucketPrices-1
+	tests := []struct {
+		name     string
+		seasonal map[string]Average
+		travel   time.Time
+		expected float64
+	}{
+		{"lead and weekday", map[string]Average{"lead7-13/wed": {Avg: 10, N: full}, "lead7-13": {Avg: 20, N: full}, "wed": {Avg: 30, N: full}}, travel, 10},
+		{"lead", map[string]Average{"lead7-13/wed": {Avg: 10, N: sparse}, "lead7-13": {Avg: 20, N: full}, "wed": {Avg: 30, N: full}}, travel, 20},
+		{"weekday", map[string]Average{"lead7-13/wed": {Avg: 10, N: sparse}, "lead7-13": {Avg: 20, N: sparse}, "wed": {Avg: 30, N: full}}, travel, 30},
+		{"overall", map[string]Average{"lead7-13/wed": {Avg: 10, N: sparse}, "lead7-13": {Avg: 20, N: sparse}, "wed": {Avg: 30, N: sparse}}, travel, 40},
+		{"other buckets", map[string]Average{"lead0-2/wed": {Avg: 10, N: full}, "thu": {Avg: 30, N: full}}, travel, 40},
+		{"no travel date", map[string]Average{"lead7-13/wed": {Avg: 10, N: full}}, time.Time{}, 40},
+	}
+	for _, test := range tests {
+		apm := &AveragePriceModel{
+			Averages: map[string]Average{"7": {Avg: 40, N: 1}},
+			Seasonal: map[string]map[string]Average{"7": test.seasonal},
+		}
+		avg, ok := apm.AverageOn("7", test.travel, search)
+		if !ok || avg.Avg != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, avg.Avg)
+		}
+	}
+
+	apm := &AveragePriceModel{Averages: map[string]Average{}}
+	if _, ok := apm.AverageOn("7", travel, search); ok {
+		t.Error("Expected no average for an unknown target")
+	}
+}
+
+func TestVersionQuery(t *testing.T) {
+	if q := VersionQuery(7, 3); q["nodeId"] != 7 || q["version"] != 3 {
+		t.Errorf("Expected the query of version 3, got %v", q)
+	}
+	// Documents written before versioning have no version.
+	q := VersionQuery(7, 0)
+	if in := q["version"].(bson.M)["$in"].([]interface{}); len(in) != 2 || in[0] != 0 || in[1] != nil {
+		t.Errorf("Expected the query of version 0 to match unversioned documents, got %v", q)
+	}
+}
+
+func TestRememberUpdate(t *testing.T) {
+	apm := &AveragePriceModel{}
+	for i := 0; i <= averageUpdates; i++ {
+		apm.RememberUpdate("7", strconv.Itoa(i))
+	}
+	apm.RememberUpdate("8", "")
+	if apm.Updated("7", "0") || !apm.Updated("7", "1") || !apm.Updated("7", strconv.Itoa(averageUpdates)) {
+		t.Errorf("Expected the last %d updates remembered, got %v", averageUpdates, apm.Updates["7"])
+	}
+	if apm.Updated("8", "1") || apm.Updated("8", "") || len(apm.Updates["8"]) != 0 {
+		t.Errorf("Expected no updates of another target, got %v", apm.Updates["8"])
+	}
+}
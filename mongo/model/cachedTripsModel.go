@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jcasado94/connecc/mongo/entity"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type CachedTripsModel struct {
+	ID        bson.ObjectId `bson:"_id,omitempty"`
+	Key       string        `bson:"key"`
+	Trips     []byte        `bson:"trips"`
+	ExpiresAt time.Time     `bson:"expiresAt"`
+}
+
+func NewCachedTripsModel(ct *entity.CachedTrips) *CachedTripsModel {
+	return &CachedTripsModel{
+		Key:       ct.Key,
+		Trips:     ct.Trips,
+		ExpiresAt: ct.ExpiresAt,
+	}
+}
+
+func (ctm *CachedTripsModel) ToEntity() *entity.CachedTrips {
+	return &entity.CachedTrips{
+		Key:       ctm.Key,
+		Trips:     ctm.Trips,
+		ExpiresAt: ctm.ExpiresAt,
+	}
+}
+
+func CachedTripsModelKeyIndex() mgo.Index {
+	return mgo.Index{
+		Key:        []string{"key"},
+		Unique:     true,
+		DropDups:   true,
+		Background: true,
+	}
+}
+
+// CachedTripsModelExpiryIndex makes Mongo delete the documents once they expire.
+func CachedTripsModelExpiryIndex() mgo.Index {
+	return mgo.Index{
+		Key:         []string{"expiresAt"},
+		Background:  true,
+		ExpireAfter: time.Second,
+	}
+}
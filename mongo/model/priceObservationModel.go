@@ -0,0 +1,202 @@
+package model
+
+import (
+	"math"
+	"time"
+
+	"github.com/jcasado94/connecc/mongo/entity"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type PriceObservationModel struct {
+	ID         bson.ObjectId `bson:"_id,omitempty"`
+	NodeId     int           `bson:"nodeId"`
+	TargetId   int           `bson:"targetId"`
+	Provider   int           `bson:"provider"`
+	TravelDate time.Time     `bson:"travelDate"`
+	ScrapedAt  time.Time     `bson:"scrapedAt"`
+	TripKey    string        `bson:"tripKey"`
+	FareType   string        `bson:"fareType"`
+	Price      float64       `bson:"price"`
+	Currency   string        `bson:"currency"`
+	// RollupBatch is the rollup batch the observation was claimed by, if any.
+	RollupBatch bson.ObjectId `bson:"rollupBatch,omitempty"`
+}
+
+func NewPriceObservationModel(po *entity.PriceObservation) *PriceObservationModel {
+	return &PriceObservationModel{
+		NodeId:     po.NodeId,
+		TargetId:   po.TargetId,
+		Provider:   po.Provider,
+		TravelDate: po.TravelDate,
+		ScrapedAt:  po.ScrapedAt,
+		TripKey:    po.TripKey,
+		FareType:   po.FareType,
+		Price:      po.Price,
+		Currency:   po.Currency,
+	}
+}
+
+func (pom *PriceObservationModel) ToEntity() *entity.PriceObservation {
+	return &entity.PriceObservation{
+		NodeId:     pom.NodeId,
+		TargetId:   pom.TargetId,
+		Provider:   pom.Provider,
+		TravelDate: pom.TravelDate,
+		ScrapedAt:  pom.ScrapedAt,
+		TripKey:    pom.TripKey,
+		FareType:   pom.FareType,
+		Price:      pom.Price,
+		Currency:   pom.Currency,
+	}
+}
+
+// PriceObservationModelRouteIndex serves the queries of the observations of a route by travel date.
+func PriceObservationModelRouteIndex() mgo.Index {
+	return mgo.Index{
+		Key:        []string{"nodeId", "targetId", "travelDate", "scrapedAt"},
+		Background: true,
+	}
+}
+
+func PriceObservationModelProviderIndex() mgo.Index {
+	return mgo.Index{
+		Key:        []string{"provider", "fareType"},
+		Background: true,
+	}
+}
+
+// PriceObservationModelRetentionIndex makes Mongo delete the observations scraped longer than retention ago.
+func PriceObservationModelRetentionIndex(retention time.Duration) mgo.Index {
+	return mgo.Index{
+		Key:         []string{"scrapedAt"},
+		Background:  true,
+		ExpireAfter: retention,
+	}
+}
+
+// PriceObservationModelRollupIndex serves the queries of the observations claimed by a rollup batch.
+func PriceObservationModelRollupIndex() mgo.Index {
+	return mgo.Index{
+		Key:        []string{"rollupBatch"},
+		Background: true,
+		Sparse:     true,
+	}
+}
+
+// rollupBatches is how many of the last batches summed up into a rollup it remembers.
+const rollupBatches = 16
+
+type PriceRollupModel struct {
+	ID             bson.ObjectId `bson:"_id,omitempty"`
+	NodeId         int           `bson:"nodeId"`
+	TargetId       int           `bson:"targetId"`
+	Provider       int           `bson:"provider"`
+	TravelDate     time.Time     `bson:"travelDate"`
+	FareType       string        `bson:"fareType"`
+	Currency       string        `bson:"currency"`
+	Min            float64       `bson:"min"`
+	Max            float64       `bson:"max"`
+	Sum            float64       `bson:"sum"`
+	N              int           `bson:"n"`
+	FirstScrapedAt time.Time     `bson:"firstScrapedAt"`
+	LastScrapedAt  time.Time     `bson:"lastScrapedAt"`
+	// Batches holds the last batches of observations summed up into the rollup.
+	Batches []bson.ObjectId `bson:"batches,omitempty"`
+}
+
+func NewPriceRollupModel(pom *PriceObservationModel) *PriceRollupModel {
+	return &PriceRollupModel{
+		NodeId:         pom.NodeId,
+		TargetId:       pom.TargetId,
+		Provider:       pom.Provider,
+		TravelDate:     pom.TravelDate,
+		FareType:       pom.FareType,
+		Currency:       pom.Currency,
+		Min:            pom.Price,
+		Max:            pom.Price,
+		Sum:            pom.Price,
+		N:              1,
+		FirstScrapedAt: pom.ScrapedAt,
+		LastScrapedAt:  pom.ScrapedAt,
+	}
+}
+
+// Add sums pom up into the rollup, which must be of its key.
+func (prm *PriceRollupModel) Add(pom *PriceObservationModel) {
+	prm.Min = math.Min(prm.Min, pom.Price)
+	prm.Max = math.Max(prm.Max, pom.Price)
+	prm.Sum += pom.Price
+	prm.N++
+	if pom.ScrapedAt.Before(prm.FirstScrapedAt) {
+		prm.FirstScrapedAt = pom.ScrapedAt
+	}
+	if pom.ScrapedAt.After(prm.LastScrapedAt) {
+		prm.LastScrapedAt = pom.ScrapedAt
+	}
+}
+
+// Selector matches the stored rollup of the key of prm unless batch was already summed up into it.
+func (prm *PriceRollupModel) Selector(batch bson.ObjectId) bson.M {
+	return bson.M{
+		"nodeId": prm.NodeId, "targetId": prm.TargetId, "travelDate": prm.TravelDate,
+		"provider": prm.Provider, "fareType": prm.FareType, "currency": prm.Currency,
+		"batches": bson.M{"$ne": batch},
+	}
+}
+
+// Update sums prm up into the stored rollup of its key, remembering batch.
+func (prm *PriceRollupModel) Update(batch bson.ObjectId) bson.M {
+	return bson.M{
+		"$inc":  bson.M{"sum": prm.Sum, "n": prm.N},
+		"$min":  bson.M{"min": prm.Min, "firstScrapedAt": prm.FirstScrapedAt},
+		"$max":  bson.M{"max": prm.Max, "lastScrapedAt": prm.LastScrapedAt},
+		"$push": bson.M{"batches": bson.M{"$each": []bson.ObjectId{batch}, "$slice": -rollupBatches}},
+	}
+}
+
+type priceRollupKey struct {
+	nodeId, targetId, provider int
+	travelDate                 int64
+	fareType, currency         string
+}
+
+// PriceRollups groups observations into the rollups of their route, provider, fare type, currency and travel date.
+type PriceRollups map[priceRollupKey]*PriceRollupModel
+
+func (prs PriceRollups) Add(pom *PriceObservationModel) {
+	key := priceRollupKey{pom.NodeId, pom.TargetId, pom.Provider, pom.TravelDate.UnixNano(), pom.FareType, pom.Currency}
+	if prm, ok := prs[key]; ok {
+		prm.Add(pom)
+		return
+	}
+	prs[key] = NewPriceRollupModel(pom)
+}
+
+func (prm *PriceRollupModel) ToEntity() *entity.PriceRollup {
+	return &entity.PriceRollup{
+		NodeId:         prm.NodeId,
+		TargetId:       prm.TargetId,
+		Provider:       prm.Provider,
+		TravelDate:     prm.TravelDate,
+		FareType:       prm.FareType,
+		Currency:       prm.Currency,
+		Min:            prm.Min,
+		Max:            prm.Max,
+		Sum:            prm.Sum,
+		N:              prm.N,
+		FirstScrapedAt: prm.FirstScrapedAt,
+		LastScrapedAt:  prm.LastScrapedAt,
+	}
+}
+
+// PriceRollupModelIndex makes a single rollup per route, provider, fare type, currency and travel date.
+func PriceRollupModelIndex() mgo.Index {
+	return mgo.Index{
+		Key:        []string{"nodeId", "targetId", "travelDate", "provider", "fareType", "currency"},
+		Unique:     true,
+		DropDups:   true,
+		Background: true,
+	}
+}
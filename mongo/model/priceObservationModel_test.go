@@ -0,0 +1,59 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestPriceRollups(t *testing.T) {
+	travel := time.Date(2019, time.Month(9), 18, 0, 0, 0, 0, time.UTC)
+	scraped := time.Date(2019, time.Month(8), 1, 10, 0, 0, 0, time.UTC)
+	observation := func(fareType string, price float64, hours int) *PriceObservationModel {
+		return &PriceObservationModel{
+			NodeId: 1, TargetId: 2, Provider: 0, TravelDate: travel, FareType: fareType,
+			Price: price, Currency: "USD", ScrapedAt: scraped.Add(time.Hour * time.Duration(hours)),
+		}
+	}
+	rollups := make(PriceRollups)
+	for _, pom := range []*PriceObservationModel{
+		observation("standard", 80, 2),
+		observation("standard", 60, 0),
+		observation("bundle", 120, 1),
+		observation("standard", 100, 4),
+	} {
+		rollups.Add(pom)
+	}
+	if len(rollups) != 2 {
+		t.Fatalf("Expected a rollup per fare type, got %v", rollups)
+	}
+	for _, prm := range rollups {
+		if prm.FareType != "standard" {
+			continue
+		}
+		expected := &PriceRollupModel{
+			NodeId: 1, TargetId: 2, TravelDate: travel, FareType: "standard", Currency: "USD",
+			Min: 60, Max: 100, Sum: 240, N: 3, FirstScrapedAt: scraped, LastScrapedAt: scraped.Add(time.Hour * 4),
+		}
+		if !reflect.DeepEqual(prm, expected) {
+			t.Errorf("Expected %+v,\ngot\n %+v", expected, prm)
+		}
+
+		batch := bson.NewObjectId()
+		selector := prm.Selector(batch)
+		if selector["fareType"] != "standard" || !reflect.DeepEqual(selector["batches"], bson.M{"$ne": batch}) {
+			t.Errorf("Expected the selector to skip rollups holding the batch, got %v", selector)
+		}
+		update := prm.Update(batch)
+		if !reflect.DeepEqual(update["$inc"], bson.M{"sum": 240.0, "n": 3}) ||
+			!reflect.DeepEqual(update["$min"], bson.M{"min": 60.0, "firstScrapedAt": scraped}) ||
+			!reflect.DeepEqual(update["$max"], bson.M{"max": 100.0, "lastScrapedAt": scraped.Add(time.Hour * 4)}) {
+			t.Errorf("Unexpected update %v", update)
+		}
+		if push := update["$push"].(bson.M)["batches"].(bson.M); !reflect.DeepEqual(push["$each"], []bson.ObjectId{batch}) {
+			t.Errorf("Expected the update to remember the batch, got %v", push)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/jcasado94/connecc/mongo"
 	"github.com/jcasado94/connecc/mongo/entity"
@@ -51,7 +52,7 @@ func getAverage_should_get_avg_from_mongo(t *testing.T) {
 	}
 
 	//test
-	avg, err := apService.GetAverage(testNodeIdS, testNodeIdT)
+	avg, err := apService.GetAverage(testNodeIdS, testNodeIdT, time.Time{}, time.Time{})
 	if err != nil {
 		t.Error(err)
 	}
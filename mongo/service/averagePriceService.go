@@ -2,29 +2,48 @@ package service
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/jcasado94/connecc/mongo"
 	"github.com/jcasado94/connecc/mongo/entity"
 	"github.com/jcasado94/connecc/mongo/model"
 	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
+// maxUpdateAttempts bounds how many times UpdateAverage reads the document again when another writer changes it first.
+const maxUpdateAttempts = 5
+
 type AveragePriceService struct {
 	collection *mgo.Collection
 }
 
-func NewAveragePriceService(session *mongo.Session, dbName, colName string) *AveragePriceService {
+// NewAveragePriceService fails if the index keeping a single document per node can't be created, as when the
+// collection already holds duplicates, since updates would then go to either of them.
+func NewAveragePriceService(session *mongo.Session, dbName, colName string) (*AveragePriceService, error) {
 	collection := session.GetCollection(dbName, colName)
 	collection.EnsureIndex(model.AveragePriceModelIndex())
-	return &AveragePriceService{collection}
+	err := collection.EnsureIndex(model.AveragePriceModelNodeIndex())
+	if err != nil {
+		return nil, err
+	}
+	return &AveragePriceService{collection}, nil
 }
 
+// CreateAveragePrice inserts the document of ap.NodeId, unless another writer inserted it first.
 func (aps *AveragePriceService) CreateAveragePrice(ap *entity.AveragePrice) error {
 	apm := model.NewAveragePriceModel(ap)
-	return aps.collection.Insert(&apm)
+	err := aps.collection.Insert(&apm)
+	if mgo.IsDup(err) {
+		return nil
+	}
+	return err
 }
 
-func (aps *AveragePriceService) GetAverage(s, tInt int) (float64, error) {
+// GetAverage returns the average price from s to tInt of the trips travelling on travelDate, searched on searchDate.
+// Buckets with few prices fall back to coarser ones, down to the average of every trip, which is also returned for a
+// zero travelDate.
+func (aps *AveragePriceService) GetAverage(s, tInt int, travelDate, searchDate time.Time) (float64, error) {
 	t := strconv.Itoa(tInt)
 	query := map[string]int{"nodeId": s}
 	var ap model.AveragePriceModel
@@ -32,21 +51,95 @@ func (aps *AveragePriceService) GetAverage(s, tInt int) (float64, error) {
 	if err != nil {
 		return 0.0, newAvgDocumentNotFoundError(s)
 	}
-	if _, exists := ap.Averages[t]; !exists {
+	avg, exists := ap.AverageOn(t, travelDate, searchDate)
+	if !exists {
 		return 0.0, newAvgNotFoundError(s, tInt)
 	}
-	return ap.Averages[t].Avg, nil
+	return avg.Avg, nil
 }
 
+// AddAverage adds an empty average from s to tInt to the document of s, unless there's one already.
 func (aps *AveragePriceService) AddAverage(s, tInt int) (price float64, err error) {
+	entry := "averages." + strconv.Itoa(tInt)
+	err = aps.collection.Update(bson.M{"nodeId": s, entry: bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{entry: model.Average{}},
+		"$inc": bson.M{"version": 1},
+	})
+	if err == mgo.ErrNotFound {
+		err = nil
+	}
+	return 0.0, err
+}
+
+// GetStats returns the average price from s to tInt along with the statistics of the prices it was built from.
+func (aps *AveragePriceService) GetStats(s, tInt int) (entity.Average, error) {
 	t := strconv.Itoa(tInt)
 	query := map[string]int{"nodeId": s}
 	var ap model.AveragePriceModel
-	err = aps.collection.Find(query).One(&ap)
+	err := aps.collection.Find(query).One(&ap)
 	if err != nil {
-		return 0.0, err
+		return entity.Average{}, newAvgDocumentNotFoundError(s)
 	}
-	ap.AddAverage(t, 0.0, 0)
-	err = aps.collection.Update(query, ap)
-	return 0.0, err
+	avg, exists := ap.Averages[t]
+	if !exists {
+		return entity.Average{}, newAvgNotFoundError(s, tInt)
+	}
+	return avg.ToEntity(), nil
+}
+
+// UpdateAverage adds price to the running average and statistics of the trips from s to tInt, and to the ones of the
+// buckets of travelDate and searchDate, creating the document or the entry if there's none yet. The document is only
+// written back if no other writer changed it since it was read, reading it again otherwise. A non-empty updateId is
+// remembered along with the average, and updates under an id already added are skipped, so that retries are safe.
+func (aps *AveragePriceService) UpdateAverage(s, tInt int, price float64, travelDate, searchDate time.Time, updateId string) error {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		done, err := aps.tryUpdateAverage(s, tInt, price, travelDate, searchDate, updateId)
+		if err != nil || done {
+			return err
+		}
+	}
+	return newAvgUpdateConflictError(s, maxUpdateAttempts)
+}
+
+// tryUpdateAverage makes an attempt of UpdateAverage, telling whether it wasn't beaten by another writer.
+func (aps *AveragePriceService) tryUpdateAverage(s, tInt int, price float64, travelDate, searchDate time.Time, updateId string) (bool, error) {
+	t := strconv.Itoa(tInt)
+	keys := entity.BucketKeys(travelDate, searchDate)
+	var ap model.AveragePriceModel
+	err := aps.collection.Find(map[string]int{"nodeId": s}).One(&ap)
+	if err == mgo.ErrNotFound {
+		item, _ := entity.NewAveragePrice(s, tInt)
+		avg := item.Averages[t]
+		avg.Add(price)
+		apm := model.NewAveragePriceModel(&item)
+		apm.SetAverage(t, avg)
+		apm.AddSeasonal(t, keys, price)
+		apm.RememberUpdate(t, updateId)
+		apm.Version = 1
+		err = aps.collection.Insert(apm)
+		if mgo.IsDup(err) {
+			return false, nil
+		}
+		return err == nil, err
+	} else if err != nil {
+		return false, err
+	}
+	if ap.Updated(t, updateId) {
+		return true, nil
+	}
+	if ap.Averages == nil {
+		ap.Averages = make(map[string]model.Average)
+	}
+	avg := ap.Averages[t].ToEntity()
+	avg.Add(price)
+	ap.SetAverage(t, avg)
+	ap.AddSeasonal(t, keys, price)
+	ap.RememberUpdate(t, updateId)
+	version := ap.Version
+	ap.Version++
+	err = aps.collection.Update(model.VersionQuery(s, version), ap)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
 }
@@ -29,3 +29,17 @@ func newAvgDocumentNotFoundError(s int) AvgDocumentNotFoundError {
 func (e AvgDocumentNotFoundError) Error() string {
 	return e.What
 }
+
+type AvgUpdateConflictError struct {
+	What string
+}
+
+func newAvgUpdateConflictError(s, attempts int) AvgUpdateConflictError {
+	return AvgUpdateConflictError{
+		What: fmt.Sprintf("Document of %v in averagePrice collection kept changing over %d attempts to update it", s, attempts),
+	}
+}
+
+func (e AvgUpdateConflictError) Error() string {
+	return e.What
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"time"
+
+	"github.com/jcasado94/connecc/mongo"
+	"github.com/jcasado94/connecc/mongo/entity"
+	"github.com/jcasado94/connecc/mongo/model"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// PriceHistorySettings tells how long price observations are kept one by one.
+type PriceHistorySettings struct {
+	// RollupAge is how long after being scraped observations get summed up into rollups by Rollup.
+	RollupAge time.Duration
+	// Retention is how long after being scraped Mongo deletes the observations, rolled up or not. It should be longer
+	// than RollupAge and the time between rollups. Observations are kept until rolled up if 0.
+	Retention time.Duration
+}
+
+var DefaultPriceHistorySettings = PriceHistorySettings{
+	RollupAge: time.Hour * 24 * 30,
+	Retention: time.Hour * 24 * 90,
+}
+
+type PriceObservationService struct {
+	observations *mgo.Collection
+	rollups      *mgo.Collection
+	settings     PriceHistorySettings
+}
+
+func NewPriceObservationService(session *mongo.Session, dbName, colName, rollupColName string, settings PriceHistorySettings) *PriceObservationService {
+	observations := session.GetCollection(dbName, colName)
+	observations.EnsureIndex(model.PriceObservationModelRouteIndex())
+	observations.EnsureIndex(model.PriceObservationModelProviderIndex())
+	observations.EnsureIndex(model.PriceObservationModelRollupIndex())
+	if settings.Retention > 0 {
+		observations.EnsureIndex(model.PriceObservationModelRetentionIndex(settings.Retention))
+	}
+	rollups := session.GetCollection(dbName, rollupColName)
+	rollups.EnsureIndex(model.PriceRollupModelIndex())
+	return &PriceObservationService{observations, rollups, settings}
+}
+
+func (pos *PriceObservationService) InsertObservations(observations []*entity.PriceObservation) error {
+	if len(observations) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(observations))
+	for _, po := range observations {
+		docs = append(docs, model.NewPriceObservationModel(po))
+	}
+	return pos.observations.Insert(docs...)
+}
+
+// GetObservations returns the observations from s to t travelling from the date from until the date to, excluded,
+// sorted by travel date and scrape time.
+func (pos *PriceObservationService) GetObservations(s, t int, from, to time.Time) ([]*entity.PriceObservation, error) {
+	var poms []model.PriceObservationModel
+	err := pos.observations.Find(routeQuery(s, t, from, to)).Sort("travelDate", "scrapedAt").All(&poms)
+	if err != nil {
+		return nil, err
+	}
+	observations := make([]*entity.PriceObservation, 0, len(poms))
+	for i := range poms {
+		observations = append(observations, poms[i].ToEntity())
+	}
+	return observations, nil
+}
+
+// GetRollups returns the rollups from s to t travelling from the date from until the date to, excluded, sorted by
+// travel date.
+func (pos *PriceObservationService) GetRollups(s, t int, from, to time.Time) ([]*entity.PriceRollup, error) {
+	var prms []model.PriceRollupModel
+	err := pos.rollups.Find(routeQuery(s, t, from, to)).Sort("travelDate").All(&prms)
+	if err != nil {
+		return nil, err
+	}
+	rollups := make([]*entity.PriceRollup, 0, len(prms))
+	for i := range prms {
+		rollups = append(rollups, prms[i].ToEntity())
+	}
+	return rollups, nil
+}
+
+func routeQuery(s, t int, from, to time.Time) bson.M {
+	return bson.M{"nodeId": s, "targetId": t, "travelDate": bson.M{"$gte": from, "$lt": to}}
+}
+
+// Rollup sums up the observations scraped longer than RollupAge before now into the rollups of their route, provider,
+// fare type, currency and travel date, then removes them. It returns how many rollups were updated.
+//
+// Observations are first claimed by a batch, so that the ones inserted meanwhile are left for the next run, and rollups
+// remember the batches summed up into them. A run that fails halfway is resumed by the next one without summing up any
+// observation twice.
+func (pos *PriceObservationService) Rollup(now time.Time) (int, error) {
+	_, err := pos.observations.UpdateAll(bson.M{
+		"scrapedAt":   bson.M{"$lt": now.Add(-pos.settings.RollupAge)},
+		"rollupBatch": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"rollupBatch": bson.NewObjectId()}})
+	if err != nil {
+		return 0, err
+	}
+	var batches []bson.ObjectId
+	err = pos.observations.Find(bson.M{"rollupBatch": bson.M{"$exists": true}}).Distinct("rollupBatch", &batches)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, batch := range batches {
+		n, err := pos.rollupBatch(batch)
+		updated += n
+		if err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+// rollupBatch sums the observations claimed by batch up into their rollups, skipping the ones it was already summed up
+// into, then removes them.
+func (pos *PriceObservationService) rollupBatch(batch bson.ObjectId) (int, error) {
+	rollups := make(model.PriceRollups)
+	iter := pos.observations.Find(bson.M{"rollupBatch": batch}).Iter()
+	var pom model.PriceObservationModel
+	for iter.Next(&pom) {
+		rollups.Add(&pom)
+		pom = model.PriceObservationModel{}
+	}
+	err := iter.Close()
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, prm := range rollups {
+		// The selector doesn't match a rollup holding batch already, so the upsert tries to insert a duplicate.
+		_, err := pos.rollups.Upsert(prm.Selector(batch), prm.Update(batch))
+		if mgo.IsDup(err) {
+			continue
+		}
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	_, err = pos.observations.RemoveAll(bson.M{"rollupBatch": batch})
+	return updated, err
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"time"
+
+	"github.com/jcasado94/connecc/mongo"
+	"github.com/jcasado94/connecc/mongo/entity"
+	"github.com/jcasado94/connecc/mongo/model"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TripCacheService struct {
+	collection *mgo.Collection
+}
+
+func NewTripCacheService(session *mongo.Session, dbName, colName string) *TripCacheService {
+	collection := session.GetCollection(dbName, colName)
+	collection.EnsureIndex(model.CachedTripsModelKeyIndex())
+	collection.EnsureIndex(model.CachedTripsModelExpiryIndex())
+	return &TripCacheService{collection}
+}
+
+// GetCachedTrips returns the trips cached under key if they haven't expired by now. Mongo only deletes expired
+// documents once a minute, so they're filtered out here as well.
+func (tcs *TripCacheService) GetCachedTrips(key string, now time.Time) (*entity.CachedTrips, bool, error) {
+	var ctm model.CachedTripsModel
+	err := tcs.collection.Find(bson.M{"key": key, "expiresAt": bson.M{"$gt": now}}).One(&ctm)
+	if err == mgo.ErrNotFound {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return ctm.ToEntity(), true, nil
+}
+
+// SetCachedTrips caches ct under its key, replacing whatever was there.
+func (tcs *TripCacheService) SetCachedTrips(ct *entity.CachedTrips) error {
+	_, err := tcs.collection.Upsert(bson.M{"key": ct.Key}, model.NewCachedTripsModel(ct))
+	return err
+}
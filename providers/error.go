@@ -0,0 +1,31 @@
+package providers
+
+import "fmt"
+
+type UnknownProviderError struct {
+	What string
+}
+
+func newUnknownProviderError(provider interface{}) UnknownProviderError {
+	return UnknownProviderError{
+		What: fmt.Sprintf("Unknown provider %v", provider),
+	}
+}
+
+func (e UnknownProviderError) Error() string {
+	return e.What
+}
+
+type UnknownFareTypeError struct {
+	What string
+}
+
+func newUnknownFareTypeError(provider, fareType string) UnknownFareTypeError {
+	return UnknownFareTypeError{
+		What: fmt.Sprintf("Unknown fare type %q for provider %s", fareType, provider),
+	}
+}
+
+func (e UnknownFareTypeError) Error() string {
+	return e.What
+}
@@ -0,0 +1,175 @@
+// Package providers loads the catalogue of transport providers defined in providers.json, which gives each of them
+// the id stored in the graph, its transport mode, the currency it prices in, the fare types it sells and the defaults
+// its scraper runs with.
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+)
+
+type Mode string
+
+const (
+	Air  Mode = "air"
+	Bus  Mode = "bus"
+	Rail Mode = "rail"
+)
+
+type Provider struct {
+	Id int `json:"id"`
+	// Name is the key of the provider in the catalogue file.
+	Name      string        `json:"-"`
+	Mode      Mode          `json:"mode"`
+	Currency  string        `json:"currency"`
+	FareTypes []string      `json:"fareTypes"`
+	Scraper   ScraperConfig `json:"scraper"`
+}
+
+// ScraperConfig holds the defaults a provider's scraper is created with.
+type ScraperConfig struct {
+	// RequestInterval is the minimum time between two requests to the provider.
+	RequestInterval Duration `json:"requestInterval"`
+	// Workers bounds the requests made to the provider at once, for scrapers that make them concurrently.
+	Workers int `json:"workers,omitempty"`
+	// StopIds maps our stop ids to the provider's ones, for providers that use their own.
+	StopIds map[string]string `json:"stopIds,omitempty"`
+}
+
+// Duration is a time.Duration read from strings such as "200ms" or "1s".
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+// HasFareType tells whether fareType is one of the fare types the provider sells.
+func (p *Provider) HasFareType(fareType string) bool {
+	for _, ft := range p.FareTypes {
+		if ft == fareType {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckFareType returns an UnknownFareTypeError if fareType is not one of the fare types the provider sells.
+func (p *Provider) CheckFareType(fareType string) error {
+	if !p.HasFareType(fareType) {
+		return newUnknownFareTypeError(p.Name, fareType)
+	}
+	return nil
+}
+
+type Catalogue struct {
+	byId   map[int]*Provider
+	byName map[string]*Provider
+}
+
+// Load reads and validates the catalogue file at path.
+func Load(path string) (*Catalogue, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse reads and validates a catalogue, keyed by provider name.
+func Parse(data []byte) (*Catalogue, error) {
+	var providers map[string]*Provider
+	err := json.Unmarshal(data, &providers)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed providers catalogue: %v", err)
+	}
+	c := &Catalogue{
+		byId:   make(map[int]*Provider),
+		byName: make(map[string]*Provider),
+	}
+	for name, p := range providers {
+		p.Name = name
+		err = p.validate()
+		if err != nil {
+			return nil, err
+		}
+		if other, exists := c.byId[p.Id]; exists {
+			return nil, fmt.Errorf("Providers %s and %s share id %d", other.Name, p.Name, p.Id)
+		}
+		c.byId[p.Id] = p
+		c.byName[name] = p
+	}
+	return c, nil
+}
+
+func (p *Provider) validate() error {
+	if p.Id < 0 {
+		return fmt.Errorf("Provider %s has a negative id", p.Name)
+	}
+	switch p.Mode {
+	case Air, Bus, Rail:
+	default:
+		return fmt.Errorf("Provider %s has unknown mode %q", p.Name, p.Mode)
+	}
+	if !money.Known(p.Currency) {
+		return fmt.Errorf("Provider %s has unknown currency %q", p.Name, p.Currency)
+	}
+	if len(p.FareTypes) == 0 {
+		return fmt.Errorf("Provider %s has no fare types", p.Name)
+	}
+	seen := make(map[string]bool)
+	for _, ft := range p.FareTypes {
+		if ft == "" || seen[ft] {
+			return fmt.Errorf("Provider %s has an empty or repeated fare type %q", p.Name, ft)
+		}
+		seen[ft] = true
+	}
+	if p.Scraper.RequestInterval.Duration < 0 || p.Scraper.Workers < 0 {
+		return fmt.Errorf("Provider %s has a negative request interval or workers", p.Name)
+	}
+	return nil
+}
+
+func (c *Catalogue) ById(id int) (*Provider, error) {
+	if p, ok := c.byId[id]; ok {
+		return p, nil
+	}
+	return nil, newUnknownProviderError(id)
+}
+
+func (c *Catalogue) ByName(name string) (*Provider, error) {
+	if p, ok := c.byName[name]; ok {
+		return p, nil
+	}
+	return nil, newUnknownProviderError(name)
+}
+
+// Name returns the name of the provider with id, or its id as a string if there's none.
+func (c *Catalogue) Name(id int) string {
+	if p, ok := c.byId[id]; ok {
+		return p.Name
+	}
+	return fmt.Sprintf("%d", id)
+}
+
+// All returns every provider, sorted by id.
+func (c *Catalogue) All() []*Provider {
+	all := make([]*Provider, 0, len(c.byId))
+	for _, p := range c.byId {
+		all = append(all, p)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Id < all[j].Id })
+	return all
+}
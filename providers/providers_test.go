@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load("../providers.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := c.ByName("megabus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Id != 1 || p.Mode != Bus || p.Currency != "USD" || p.Scraper.RequestInterval.Duration != time.Millisecond*200 || p.Scraper.Workers != 4 {
+		t.Errorf("Unexpected provider %+v", p)
+	}
+	if c.Name(0) != "spirit" {
+		t.Errorf("Expected provider 0 to be spirit, got %s", c.Name(0))
+	}
+	if err := p.CheckFareType("standard"); err != nil {
+		t.Error(err)
+	}
+	if _, ok := p.CheckFareType("business").(UnknownFareTypeError); !ok {
+		t.Error("Expected an UnknownFareTypeError")
+	}
+	if _, err := c.ById(99); err == nil {
+		t.Error("Expected an error getting an unknown provider")
+	}
+	for i, p := range c.All() {
+		if p.Id != i {
+			t.Errorf("Expected providers sorted by id, got %d at %d", p.Id, i)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	testCases := map[string]string{
+		"repeated id":        `{"a": {"id": 0, "mode": "air", "currency": "USD", "fareTypes": ["standard"]}, "b": {"id": 0, "mode": "bus", "currency": "USD", "fareTypes": ["standard"]}}`,
+		"unknown mode":       `{"a": {"id": 0, "mode": "boat", "currency": "USD", "fareTypes": ["standard"]}}`,
+		"unknown currency":   `{"a": {"id": 0, "mode": "air", "currency": "XYZ", "fareTypes": ["standard"]}}`,
+		"no fare types":      `{"a": {"id": 0, "mode": "air", "currency": "USD", "fareTypes": []}}`,
+		"repeated fare type": `{"a": {"id": 0, "mode": "air", "currency": "USD", "fareTypes": ["standard", "standard"]}}`,
+		"bad interval":       `{"a": {"id": 0, "mode": "air", "currency": "USD", "fareTypes": ["standard"], "scraper": {"requestInterval": "soon"}}}`,
+	}
+	for name, data := range testCases {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing a catalogue with %s", name)
+		}
+	}
+}
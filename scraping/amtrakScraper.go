@@ -0,0 +1,174 @@
+package scraping
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+)
+
+const (
+	amtrakRequestInterval = time.Millisecond * 500
+	amtrakCurrency        = "USD"
+	amtrakDateTimeLayout  = "2006-01-02T15:04:05"
+)
+
+var amtrakSearchUrl = template.Must(template.New("amtrakUrl").Parse(`https://www.amtrak.com/dotcom/journey-solution-option`))
+
+// amtrakPassengerTypes maps Amtrak's passenger type codes to ours. Amtrak counts infants (J) apart from children (C).
+var amtrakPassengerTypes = map[string]PassengerType{
+	"F": Adult,
+	"C": Child,
+	"J": Infant,
+}
+
+type JsonAmJourneyRequest struct {
+	JourneyRequest struct {
+		Type               string                    `json:"type"`
+		JourneyLegRequests []JsonAmJourneyLegRequest `json:"journeyLegRequests"`
+		PassengerTypes     []JsonAmPassengerCount    `json:"passengerTypes"`
+	} `json:"journeyRequest"`
+}
+
+type JsonAmJourneyLegRequest struct {
+	Origin struct {
+		Code     string `json:"code"`
+		Schedule struct {
+			DepartureDateTime string `json:"departureDateTime"`
+		} `json:"schedule"`
+	} `json:"origin"`
+	Destination struct {
+		Code string `json:"code"`
+	} `json:"destination"`
+}
+
+type JsonAmPassengerCount struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// amtrakSearchBody requests the one-way trips of a single leg.
+func amtrakSearchBody(query *JsonApiQuery) interface{} {
+	var leg JsonAmJourneyLegRequest
+	leg.Origin.Code = query.Departure
+	leg.Origin.Schedule.DepartureDateTime = query.Date.Format(amtrakDateTimeLayout)
+	leg.Destination.Code = query.Arrival
+	var req JsonAmJourneyRequest
+	req.JourneyRequest.Type = "OW"
+	req.JourneyRequest.JourneyLegRequests = []JsonAmJourneyLegRequest{leg}
+	req.JourneyRequest.PassengerTypes = []JsonAmPassengerCount{
+		{"F", query.Adults},
+		{"C", query.Children},
+		{"J", query.Infants},
+	}
+	return req
+}
+
+type JsonAmJourneySolutions struct {
+	JourneySolutionOption struct {
+		JourneyLegs []struct {
+			JourneyLegOptions []JsonAmJourneyLegOption `json:"journeyLegOptions"`
+		} `json:"journeyLegs"`
+	} `json:"journeySolutionOption"`
+}
+
+type JsonAmJourneyLegOption struct {
+	TravelLegs               []JsonAmTravelLeg               `json:"travelLegs"`
+	ReservableAccommodations []JsonAmReservableAccommodation `json:"reservableAccommodations"`
+}
+
+type JsonAmTravelLeg struct {
+	Origin struct {
+		Code string `json:"code"`
+	} `json:"origin"`
+	Destination struct {
+		Code string `json:"code"`
+	} `json:"destination"`
+	DepartureDateTime string `json:"departureDateTime"`
+	ArrivalDateTime   string `json:"arrivalDateTime"`
+	TravelService     struct {
+		Number string `json:"number"`
+		Name   string `json:"name"`
+	} `json:"travelService"`
+}
+
+type JsonAmReservableAccommodation struct {
+	FareFamily     string `json:"fareFamily"`
+	PassengerFares []struct {
+		Type  string `json:"type"`
+		Count int    `json:"count"`
+		// Total is the price for every traveller of Type.
+		Total string `json:"total"`
+	} `json:"passengerFares"`
+}
+
+// NewAmtrakScraper creates a scraper for Amtrak, searched by station code. Legs carry the train number as Id and
+// fares are named after Amtrak's fare families, such as "saver" or "business". Prices include taxes.
+func NewAmtrakScraper() *JsonApiScraper {
+	return NewJsonApiScraper(JsonApiConfig{
+		Name:            "Amtrak",
+		SearchUrl:       amtrakSearchUrl,
+		SearchBody:      amtrakSearchBody,
+		Decode:          decodeAmtrakJourneySolutions,
+		Currency:        amtrakCurrency,
+		TaxesIncluded:   true,
+		RequestInterval: amtrakRequestInterval,
+		MaxPages:        1,
+	})
+}
+
+func decodeAmtrakJourneySolutions(body []byte) (*JsonApiPage, error) {
+	var solutions JsonAmJourneySolutions
+	err := json.Unmarshal(body, &solutions)
+	if err != nil {
+		return nil, err
+	}
+	page := &JsonApiPage{}
+	for _, jl := range solutions.JourneySolutionOption.JourneyLegs {
+		for _, option := range jl.JourneyLegOptions {
+			trip := JsonApiTrip{}
+			for _, tl := range option.TravelLegs {
+				depTime, err := time.Parse(amtrakDateTimeLayout, tl.DepartureDateTime)
+				if err != nil {
+					return nil, err
+				}
+				arrTime, err := time.Parse(amtrakDateTimeLayout, tl.ArrivalDateTime)
+				if err != nil {
+					return nil, err
+				}
+				trip.Legs = append(trip.Legs, JsonApiLeg{
+					Dep:     tl.Origin.Code,
+					Arr:     tl.Destination.Code,
+					DepTime: depTime,
+					ArrTime: arrTime,
+					Id:      tl.TravelService.Number,
+				})
+			}
+			for _, ra := range option.ReservableAccommodations {
+				fare := JsonApiFare{
+					Type:   strings.ToLower(ra.FareFamily),
+					Prices: map[PassengerType]float64{Infant: 0},
+				}
+				for _, pf := range ra.PassengerFares {
+					pt, ok := amtrakPassengerTypes[pf.Type]
+					if !ok {
+						continue
+					}
+					price, err := money.Parse(pf.Total, amtrakCurrency)
+					if err != nil {
+						return nil, err
+					}
+					if pf.Count > 1 {
+						price.Amount /= int64(pf.Count)
+					}
+					fare.Prices[pt] = price.Float64()
+				}
+				trip.Fares = append(trip.Fares, fare)
+			}
+			page.Trips = append(page.Trips, trip)
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,60 @@
+package scraping
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestGetTripsAmtrak(t *testing.T) {
+	sc := NewAmtrakScraper()
+	sc.client.Transport = newCassetteTransport(t, "amtrak")
+	expectedTrips := []*Trip{
+		&Trip{
+			Fares: []*Fare{adultFare("business", 139.0, true), adultFare("premium", 219.0, true)},
+			Legs:  []*Leg{&Leg{Dep: "BOS", Arr: "NYP", Id: "2150", DepTime: time.Date(2019, time.Month(9), 8, 6, 15, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 10, 20, 0, 0, time.UTC)}},
+		},
+		&Trip{
+			Fares: []*Fare{adultFare("saver", 29.0, true), adultFare("value", 49.0, true), adultFare("flexible", 98.0, true), adultFare("business", 84.0, true)},
+			Legs:  []*Leg{&Leg{Dep: "BOS", Arr: "NYP", Id: "171", DepTime: time.Date(2019, time.Month(9), 8, 9, 40, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 13, 55, 0, 0, time.UTC)}},
+		},
+		&Trip{
+			Fares: []*Fare{adultFare("value", 62.0, true)},
+			Legs: []*Leg{&Leg{Dep: "BOS", Arr: "PVD", Id: "2170", DepTime: time.Date(2019, time.Month(9), 8, 17, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 17, 40, 0, 0, time.UTC)},
+				&Leg{Dep: "PVD", Arr: "NYP", Id: "95", DepTime: time.Date(2019, time.Month(9), 8, 18, 24, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 21, 50, 0, 0, time.UTC)},
+			},
+		},
+	}
+	trips, err := sc.GetTrips("BOS", "NYP", 8, 9, 2019, 1, 0, 0)
+	if err != nil {
+		t.Fatalf("Couldn't retrieve trips.\n%v", err)
+	}
+	checkTrips(t, expectedTrips, trips)
+}
+
+func TestAmtrakSearchBody(t *testing.T) {
+	query := &JsonApiQuery{Departure: "BOS", Arrival: "NYP", Date: time.Date(2019, time.Month(9), 8, 0, 0, 0, 0, time.UTC), Adults: 1}
+	body, err := json.Marshal(amtrakSearchBody(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"journeyRequest":{"type":"OW","journeyLegRequests":[{"origin":{"code":"BOS","schedule":{"departureDateTime":"2019-09-08T00:00:00"}},"destination":{"code":"NYP"}}],"passengerTypes":[{"type":"F","count":1},{"type":"C","count":0},{"type":"J","count":0}]}}`
+	if string(body) != expected {
+		t.Errorf("Expected %s,\ngot\n %s", expected, body)
+	}
+
+	// Station codes are escaped rather than breaking out of their string.
+	query.Departure = `BOS","type":"RT`
+	body, err = json.Marshal(amtrakSearchBody(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded JsonAmJourneyRequest
+	err = json.Unmarshal(body, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.JourneyRequest.Type != "OW" || decoded.JourneyRequest.JourneyLegRequests[0].Origin.Code != query.Departure {
+		t.Errorf("Expected the station code to be kept whole, got %s", body)
+	}
+}
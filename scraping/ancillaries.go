@@ -0,0 +1,125 @@
+package scraping
+
+import (
+	"fmt"
+
+	"github.com/jcasado94/connecc/money"
+)
+
+// Extras are the bags each traveller of the party adds to a trip.
+type Extras struct {
+	CarryOns    int
+	CheckedBags int
+}
+
+// BagFees prices the bags a traveller adds to a trip booked with a given fare, on top of the ones the fare includes.
+type BagFees struct {
+	CarryOn money.Money
+	// CheckedBags holds the price of the first, second... checked bag. Any further bag costs as much as the last one.
+	CheckedBags         []money.Money
+	IncludedCarryOns    int
+	IncludedCheckedBags int
+}
+
+// AncillaryFees holds the bag fees of a provider, which may depend on the fare type. Bags are charged per traveller
+// and trip, so a round trip combined with CombineTrips pays them twice.
+type AncillaryFees struct {
+	Default    BagFees
+	ByFareType map[string]BagFees
+}
+
+// SpiritAncillaryFees returns Spirit's bag fees when paid online while booking. Spirit prices bags by route and
+// date, so these are only typical values to be overridden when exact prices matter.
+func SpiritAncillaryFees() *AncillaryFees {
+	standard := BagFees{
+		CarryOn:     money.New(3700, spiritCurrency),
+		CheckedBags: []money.Money{money.New(3200, spiritCurrency), money.New(4200, spiritCurrency), money.New(8000, spiritCurrency)},
+	}
+	bundle := standard
+	bundle.IncludedCarryOns, bundle.IncludedCheckedBags = 1, 1
+	return &AncillaryFees{
+		Default: standard,
+		ByFareType: map[string]BagFees{
+			"9Dollar": {
+				CarryOn:     money.New(2600, spiritCurrency),
+				CheckedBags: []money.Money{money.New(2100, spiritCurrency), money.New(3100, spiritCurrency), money.New(7000, spiritCurrency)},
+			},
+			"bundleIt": bundle,
+		},
+	}
+}
+
+func (af *AncillaryFees) bagFees(fareType string) BagFees {
+	if bf, ok := af.ByFareType[fareType]; ok {
+		return bf
+	}
+	return af.Default
+}
+
+// perTraveller returns what a single traveller pays for the extras, in currency.
+func (bf BagFees) perTraveller(extras Extras, currency string) (money.Money, error) {
+	fees := make([]money.Money, 0)
+	for i := bf.IncludedCarryOns; i < extras.CarryOns; i++ {
+		fees = append(fees, bf.CarryOn)
+	}
+	for i := bf.IncludedCheckedBags; i < extras.CheckedBags; i++ {
+		if len(bf.CheckedBags) == 0 {
+			return money.Money{}, fmt.Errorf("No checked bag fees configured")
+		}
+		if i < len(bf.CheckedBags) {
+			fees = append(fees, bf.CheckedBags[i])
+		} else {
+			fees = append(fees, bf.CheckedBags[len(bf.CheckedBags)-1])
+		}
+	}
+
+	total := money.New(0, currency)
+	for _, fee := range fees {
+		if fee.IsZero() {
+			continue
+		}
+		var err error
+		total, err = total.Add(fee)
+		if err != nil {
+			return money.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// PriceWith returns the price of fare f for the whole party once every traveller, except lap infants, adds extras.
+// passengers must be the party the fare was scraped for.
+func (af *AncillaryFees) PriceWith(f *Fare, passengers Passengers, extras Extras) (money.Money, error) {
+	perTraveller, err := af.bagFees(f.Type).perTraveller(extras, f.Total.Currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return f.Total.Add(perTraveller.Times(passengers.count() - passengers[Infant]))
+}
+
+// CheapestWith returns the trip and fare with the lowest price for the party once extras are added, along with that price.
+// All fares must be in the same currency. It returns nil if no trip has fares.
+func CheapestWith(trips []*Trip, fees *AncillaryFees, passengers Passengers, extras Extras) (*Trip, *Fare, money.Money, error) {
+	var cheapestTrip *Trip
+	var cheapestFare *Fare
+	var cheapestPrice money.Money
+	for _, t := range trips {
+		for _, f := range t.Fares {
+			price, err := fees.PriceWith(f, passengers, extras)
+			if err != nil {
+				return nil, nil, money.Money{}, err
+			}
+			if cheapestFare != nil {
+				cmp, err := price.Cmp(cheapestPrice)
+				if err != nil {
+					return nil, nil, money.Money{}, err
+				}
+				if cmp >= 0 {
+					continue
+				}
+			}
+			cheapestTrip, cheapestFare, cheapestPrice = t, f, price
+		}
+	}
+	return cheapestTrip, cheapestFare, cheapestPrice, nil
+}
@@ -0,0 +1,170 @@
+package scraping
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// TripCache stores the trips found by searches under a key, for as long as a given TTL.
+type TripCache interface {
+	// Get returns the trips stored under key, and whether there were any that hadn't expired yet.
+	Get(key string) ([]*Trip, bool, error)
+	Set(key string, trips []*Trip, ttl time.Duration) error
+}
+
+// TTLPolicy tells how long the trips found for a date that is leadTime away can be reused. Prices change faster as the
+// date gets closer.
+type TTLPolicy func(leadTime time.Duration) time.Duration
+
+// DefaultTTL reuses trips for 15 minutes within 3 days of the travel date, and up to a day for dates months away.
+func DefaultTTL(leadTime time.Duration) time.Duration {
+	day := time.Hour * 24
+	switch {
+	case leadTime < 3*day:
+		return time.Minute * 15
+	case leadTime < 14*day:
+		return time.Hour
+	case leadTime < 60*day:
+		return time.Hour * 6
+	}
+	return day
+}
+
+func cacheKey(provider, departure, arrival string, day, month, year, adults, children, infants int) string {
+	return fmt.Sprintf("%s:%s-%s:%04d-%02d-%02d:%d/%d/%d", provider, departure, arrival, year, month, day, adults, children, infants)
+}
+
+type cachingScraper struct {
+	scraper  Scraper
+	provider string
+	cache    TripCache
+	ttl      TTLPolicy
+	now      func() time.Time
+}
+
+// NewCachingScraper puts cache in front of sc, the scraper of provider, so that searches for the same route, date and
+// passengers are only made again once the trips found expire according to ttl. DefaultTTL is used if ttl is nil.
+// Empty results are cached too, while failed searches are not. The cache is best-effort: errors reading or writing it
+// are logged and the search goes on without it.
+func NewCachingScraper(provider string, sc Scraper, cache TripCache, ttl TTLPolicy) Scraper {
+	if ttl == nil {
+		ttl = DefaultTTL
+	}
+	return &cachingScraper{
+		scraper:  sc,
+		provider: provider,
+		cache:    cache,
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+func (sc *cachingScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
+	key := cacheKey(sc.provider, departure, arrival, day, month, year, adults, children, infants)
+	trips, ok, err := sc.cache.Get(key)
+	if err != nil {
+		log.Printf("Trip cache. Couldn't read %s: %v", key, err)
+	} else if ok {
+		return trips, nil
+	}
+
+	trips, err = sc.scraper.GetTrips(departure, arrival, day, month, year, adults, children, infants)
+	if err != nil {
+		return []*Trip{}, err
+	}
+	leadTime := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Sub(sc.now())
+	err = sc.cache.Set(key, trips, sc.ttl(leadTime))
+	if err != nil {
+		log.Printf("Trip cache. Couldn't write %s: %v", key, err)
+	}
+	return trips, nil
+}
+
+// defaultMemoryCacheEntries is the number of searches a MemoryTripCache keeps the trips of by default.
+const defaultMemoryCacheEntries = 10000
+
+type memoryCacheEntry struct {
+	trips     []*Trip
+	expiresAt time.Time
+}
+
+// MemoryTripCache is a TripCache kept in memory, holding up to a maximum number of entries. Expired entries are dropped
+// as they are found, and all of them once the cache is full, after which the entry closest to expiring makes room for
+// new ones. Trips are copied in and out, so callers are free to change the ones they pass or get.
+type MemoryTripCache struct {
+	mu         sync.Mutex
+	entries    map[string]memoryCacheEntry
+	maxEntries int
+	now        func() time.Time
+}
+
+// NewMemoryTripCache creates a MemoryTripCache holding up to defaultMemoryCacheEntries entries.
+func NewMemoryTripCache() *MemoryTripCache {
+	return NewMemoryTripCacheSize(defaultMemoryCacheEntries)
+}
+
+// NewMemoryTripCacheSize creates a MemoryTripCache holding up to entries entries.
+func NewMemoryTripCacheSize(entries int) *MemoryTripCache {
+	if entries < 1 {
+		entries = 1
+	}
+	return &MemoryTripCache{
+		entries:    make(map[string]memoryCacheEntry),
+		maxEntries: entries,
+		now:        time.Now,
+	}
+}
+
+func (c *MemoryTripCache) Get(key string) ([]*Trip, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return copyTrips(entry.trips), true, nil
+}
+
+func (c *MemoryTripCache) Set(key string, trips []*Trip, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[key] = memoryCacheEntry{
+		trips:     copyTrips(trips),
+		expiresAt: c.now().Add(ttl),
+	}
+	return nil
+}
+
+// evict drops the expired entries, or the one closest to expiring if none has.
+func (c *MemoryTripCache) evict() {
+	now := c.now()
+	var next string
+	var nextExpiresAt time.Time
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		} else if nextExpiresAt.IsZero() || entry.expiresAt.Before(nextExpiresAt) {
+			next, nextExpiresAt = key, entry.expiresAt
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, next)
+	}
+}
+
+func copyTrips(trips []*Trip) []*Trip {
+	copied := make([]*Trip, 0, len(trips))
+	for _, t := range trips {
+		copied = append(copied, t.copy())
+	}
+	return copied
+}
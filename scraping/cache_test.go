@@ -0,0 +1,133 @@
+package scraping
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+)
+
+type countingScraper struct {
+	calls int
+}
+
+func (sc *countingScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
+	sc.calls++
+	return []*Trip{newTrip([]*Fare{adultFare("standard", float64(sc.calls), false)}, []*Leg{})}, nil
+}
+
+func TestCachingScraper(t *testing.T) {
+	now := time.Date(2019, time.Month(9), 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	cache := NewMemoryTripCache()
+	cache.now = clock
+	inner := &countingScraper{}
+	sc := NewCachingScraper("megabus", inner, cache, nil).(*cachingScraper)
+	sc.now = clock
+
+	search := func(day, adults int) *Trip {
+		trips, err := sc.GetTrips("123", "289", day, 9, 2019, adults, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return trips[0]
+	}
+
+	// The 2nd is less than a day away, so its trips are reused for 15 minutes, while the ones on the 30th are for 6 hours.
+	first := search(2, 1)
+	if !search(2, 1).Fares[0].Equals(first.Fares[0]) || inner.calls != 1 {
+		t.Errorf("Expected the second search to be served from the cache, got %d searches", inner.calls)
+	}
+	search(2, 2)
+	search(30, 1)
+	if inner.calls != 3 {
+		t.Errorf("Expected different passengers and dates to be searched, got %d searches", inner.calls)
+	}
+
+	now = now.Add(time.Minute * 20)
+	search(2, 1)
+	search(30, 1)
+	if inner.calls != 4 {
+		t.Errorf("Expected only the search on the 2nd to expire, got %d searches", inner.calls)
+	}
+}
+
+func TestMemoryTripCacheCopies(t *testing.T) {
+	cache := NewMemoryTripCache()
+	trips := []*Trip{newTrip([]*Fare{adultFare("standard", 10, false)}, []*Leg{&Leg{Dep: "123", Arr: "289", Id: "M1"}})}
+	cache.Set("key", trips, time.Hour)
+	trips[0].Fares[0].Prices[Adult] = money.New(0, "USD")
+	trips[0].Legs[0].Id = "M2"
+
+	cached, _, _ := cache.Get("key")
+	cached[0].Fares = cached[0].Fares[:0]
+	cached, ok, err := cache.Get("key")
+	if err != nil || !ok {
+		t.Fatalf("Expected the trips to be cached, got %v, %v", ok, err)
+	}
+	expected := []*Trip{newTrip([]*Fare{adultFare("standard", 10, false)}, []*Leg{&Leg{Dep: "123", Arr: "289", Id: "M1"}})}
+	checkTrips(t, expected, cached)
+}
+
+func TestMemoryTripCacheEviction(t *testing.T) {
+	now := time.Date(2019, time.Month(9), 1, 12, 0, 0, 0, time.UTC)
+	cache := NewMemoryTripCacheSize(2)
+	cache.now = func() time.Time { return now }
+	cache.Set("a", []*Trip{}, time.Minute)
+	cache.Set("b", []*Trip{}, time.Hour)
+	cache.Set("c", []*Trip{}, time.Hour*2)
+	if _, ok, _ := cache.Get("a"); ok {
+		t.Error("Expected the entry closest to expiring to make room")
+	}
+
+	now = now.Add(time.Minute * 90)
+	cache.Set("d", []*Trip{}, time.Hour)
+	if len(cache.entries) != 2 {
+		t.Errorf("Expected the expired entries to be swept, got %v", cache.entries)
+	}
+	for _, key := range []string{"c", "d"} {
+		if _, ok, _ := cache.Get(key); !ok {
+			t.Errorf("Expected %s to be cached", key)
+		}
+	}
+}
+
+type failingTripCache struct{}
+
+func (c failingTripCache) Get(key string) ([]*Trip, bool, error) {
+	return nil, false, fmt.Errorf("no reachable servers")
+}
+
+func (c failingTripCache) Set(key string, trips []*Trip, ttl time.Duration) error {
+	return fmt.Errorf("no reachable servers")
+}
+
+func TestCachingScraperCacheDown(t *testing.T) {
+	inner := &countingScraper{}
+	sc := NewCachingScraper("megabus", inner, failingTripCache{}, nil)
+	for i := 1; i <= 2; i++ {
+		trips, err := sc.GetTrips("123", "289", 2, 9, 2019, 1, 0, 0)
+		if err != nil || len(trips) != 1 || inner.calls != i {
+			t.Errorf("Expected search %d to go through to the scraper, got %v, %v after %d searches", i, trips, err, inner.calls)
+		}
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	day := time.Hour * 24
+	testCases := []struct {
+		leadTime, expected time.Duration
+	}{
+		{-time.Hour, time.Minute * 15},
+		{2 * day, time.Minute * 15},
+		{7 * day, time.Hour},
+		{30 * day, time.Hour * 6},
+		{120 * day, day},
+	}
+	for _, tc := range testCases {
+		if ttl := DefaultTTL(tc.leadTime); ttl != tc.expected {
+			t.Errorf("TTL for %v differs. Want %v, got %v", tc.leadTime, tc.expected, ttl)
+		}
+	}
+}
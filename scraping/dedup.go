@@ -0,0 +1,173 @@
+package scraping
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+)
+
+// Key identifies the service a leg stands for whichever itinerary or scrape it comes from: its carrier id, endpoints
+// and times. Blanks and case in the id don't matter, so "NK 123" and "nk123" are the same flight.
+func (l *Leg) Key() string {
+	id := strings.ToUpper(strings.Join(strings.Fields(l.Id), ""))
+	return fmt.Sprintf("%s|%s|%s|%d|%d", id, strings.TrimSpace(l.Dep), strings.TrimSpace(l.Arr), l.DepTime.Unix(), l.ArrTime.Unix())
+}
+
+// Key identifies a trip by the keys of its legs.
+func (t *Trip) Key() string {
+	keys := make([]string, 0, len(t.Legs))
+	for _, l := range t.Legs {
+		keys = append(keys, l.Key())
+	}
+	return strings.Join(keys, "/")
+}
+
+// mergeTrips returns the trip holding the fares of both old and new, which must share their key. Fares of the same
+// type are taken from the latest scraped, new on a tie.
+func mergeTrips(old, new *Trip) *Trip {
+	latest, earliest := new, old
+	if new.ScrapedAt.Before(old.ScrapedAt) {
+		latest, earliest = old, new
+	}
+	merged := &Trip{
+		Legs:      latest.Legs,
+		Fares:     make([]*Fare, 0, len(latest.Fares)),
+		Provider:  latest.Provider,
+		ScrapedAt: latest.ScrapedAt,
+	}
+	merged.Fares = append(merged.Fares, latest.Fares...)
+	for _, f := range earliest.Fares {
+		if latest.getFare(f.Type) == nil {
+			merged.Fares = append(merged.Fares, f)
+		}
+	}
+	return merged
+}
+
+// DedupeTrips merges the trips sharing a key, as the same flights listed within several itineraries, keeping the
+// order in which they first appear.
+func DedupeTrips(trips []*Trip) []*Trip {
+	deduped := make([]*Trip, 0, len(trips))
+	index := make(map[string]int)
+	for _, t := range trips {
+		key := t.Key()
+		if i, ok := index[key]; ok {
+			deduped[i] = mergeTrips(deduped[i], t)
+			continue
+		}
+		index[key] = len(deduped)
+		deduped = append(deduped, t)
+	}
+	return deduped
+}
+
+// PricePoint is the price of a fare as seen at some point.
+type PricePoint struct {
+	Price, Total money.Money
+	At           time.Time
+}
+
+type dedupEntry struct {
+	trip *Trip
+	// history holds the price points of each fare type, oldest first.
+	history map[string][]PricePoint
+}
+
+// Deduper merges the trips found across scrapes and providers, so that every trip is known once with the latest price
+// of each of its fares, and keeps the history of those prices.
+type Deduper struct {
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	now     func() time.Time
+}
+
+func NewDeduper() *Deduper {
+	return &Deduper{
+		entries: make(map[string]*dedupEntry),
+		now:     time.Now,
+	}
+}
+
+// Add merges trips with the ones already known. It returns trips deduplicated, holding only the fares found by this
+// scrape, so that fares gone since an earlier one aren't taken as still on sale. Latest gives the trips with every
+// fare known. Trips with no ScrapedAt are taken as scraped now.
+func (d *Deduper) Add(trips []*Trip) []*Trip {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	added := DedupeTrips(trips)
+	for i, t := range added {
+		if t.ScrapedAt.IsZero() {
+			stamped := *t
+			stamped.ScrapedAt = now
+			t = &stamped
+			added[i] = t
+		}
+		key := t.Key()
+		e, ok := d.entries[key]
+		if !ok {
+			e = &dedupEntry{trip: t, history: make(map[string][]PricePoint)}
+			d.entries[key] = e
+		} else {
+			e.trip = mergeTrips(e.trip, t)
+		}
+		for _, f := range t.Fares {
+			e.record(f, t.ScrapedAt)
+		}
+	}
+	return added
+}
+
+// Latest returns the trip known under the key of t, holding the latest price of every fare found for it, or nil if
+// there's none.
+func (d *Deduper) Latest(t *Trip) *Trip {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.entries[t.Key()]
+	if !ok {
+		return nil
+	}
+	return e.trip
+}
+
+// record adds the price of f at some time to the history of its fare type, unless it didn't change since the point
+// before.
+func (e *dedupEntry) record(f *Fare, at time.Time) {
+	points := e.history[f.Type]
+	i := len(points)
+	for i > 0 && points[i-1].At.After(at) {
+		i--
+	}
+	if i > 0 && points[i-1].Price == f.Price && points[i-1].Total == f.Total {
+		return
+	}
+	points = append(points, PricePoint{})
+	copy(points[i+1:], points[i:])
+	points[i] = PricePoint{f.Price, f.Total, at}
+	e.history[f.Type] = points
+}
+
+// History returns the prices seen for the fare of t of fareType, oldest first.
+func (d *Deduper) History(t *Trip, fareType string) []PricePoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.entries[t.Key()]
+	if !ok {
+		return nil
+	}
+	return append([]PricePoint(nil), e.history[fareType]...)
+}
+
+// Prune forgets the trips departing before some time, which won't be scraped again.
+func (d *Deduper) Prune(before time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, e := range d.entries {
+		if len(e.trip.Legs) == 0 || e.trip.Legs[0].DepTime.Before(before) {
+			delete(d.entries, key)
+		}
+	}
+}
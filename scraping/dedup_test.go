@@ -0,0 +1,76 @@
+package scraping
+
+import (
+	"testing"
+	"time"
+)
+
+func dedupTestTrip(id string, scrapedAt time.Time, fares ...*Fare) *Trip {
+	dep := time.Date(2019, time.Month(9), 8, 7, 0, 0, 0, time.UTC)
+	return &Trip{
+		Legs: []*Leg{
+			newLeg("BOS", "FLL", id, dep, dep.Add(time.Hour*3)),
+			newLeg("FLL", "DEN", "NK456", dep.Add(time.Hour*5), dep.Add(time.Hour*8)),
+		},
+		Fares:     fares,
+		ScrapedAt: scrapedAt,
+	}
+}
+
+func TestDedupeTrips(t *testing.T) {
+	at := time.Date(2019, time.Month(9), 1, 10, 0, 0, 0, time.UTC)
+	trips := []*Trip{
+		dedupTestTrip("NK123", at, adultFare("standard", 120, true)),
+		dedupTestTrip("NK 124", at, adultFare("standard", 150, true)),
+		dedupTestTrip("nk 123", at, adultFare("standard", 110, true), adultFare("member", 90, true)),
+	}
+	deduped := DedupeTrips(trips)
+	if len(deduped) != 2 {
+		t.Fatalf("Expected 2 trips, got %d", len(deduped))
+	}
+	if deduped[0].Legs[0].Id != "nk 123" || deduped[1].Legs[0].Id != "NK 124" {
+		t.Errorf("Trips out of order: %v", deduped)
+	}
+	checkTrips(t, []*Trip{{Fares: []*Fare{adultFare("standard", 110, true), adultFare("member", 90, true)}, Legs: trips[2].Legs}}, deduped[:1])
+}
+
+func TestDeduper(t *testing.T) {
+	d := NewDeduper()
+	first := time.Date(2019, time.Month(9), 1, 10, 0, 0, 0, time.UTC)
+	second, third := first.Add(time.Hour), first.Add(time.Hour*2)
+
+	d.Add([]*Trip{dedupTestTrip("NK123", first, adultFare("standard", 120, true), adultFare("member", 90, true))})
+	d.Add([]*Trip{dedupTestTrip("NK123", third, adultFare("standard", 130, true))})
+	// A late scrape doesn't override the latest price, but makes its way into the history.
+	late := dedupTestTrip("nk 123", second, adultFare("standard", 125, true))
+	added := d.Add([]*Trip{late, dedupTestTrip("nk 123", second, adultFare("bundle", 200, true))})
+	// Add only returns the fares of the scrape, with the duplicates merged.
+	checkTrips(t, []*Trip{dedupTestTrip("nk 123", second, adultFare("bundle", 200, true), adultFare("standard", 125, true))}, added)
+	expected := dedupTestTrip("NK123", third, adultFare("standard", 130, true), adultFare("member", 90, true), adultFare("bundle", 200, true))
+	merged := d.Latest(late)
+	checkTrips(t, []*Trip{expected}, []*Trip{merged})
+	if !merged.ScrapedAt.Equal(third) {
+		t.Errorf("Expected the trip scraped at %v, got %v", third, merged.ScrapedAt)
+	}
+
+	history := d.History(expected, "standard")
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 price points, got %v", history)
+	}
+	for i, p := range []float64{120, 125, 130} {
+		if history[i].Price.Float64() != p {
+			t.Errorf("Expected price point %d at %v, got %v", i, p, history[i].Price)
+		}
+	}
+
+	// Unchanged prices don't add points.
+	d.Add([]*Trip{dedupTestTrip("NK123", third.Add(time.Hour), adultFare("member", 90, true))})
+	if history = d.History(expected, "member"); len(history) != 1 {
+		t.Errorf("Expected a single price point, got %v", history)
+	}
+
+	d.Prune(time.Date(2019, time.Month(9), 9, 0, 0, 0, 0, time.UTC))
+	if history = d.History(expected, "standard"); history != nil {
+		t.Errorf("Expected the trip to be forgotten, got %v", history)
+	}
+}
@@ -0,0 +1,152 @@
+package scraping
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+)
+
+// TripSchemaVersion is the version of the JSON encoding of trips. It must be increased whenever a field changes
+// meaning or is removed, so that readers of older versions fail instead of misreading the new ones. Version 1 encoded
+// leg times with an offset, as if they weren't local.
+const TripSchemaVersion = 2
+
+// legTimeLayout encodes the local wall-clock times of legs, which have no offset.
+const legTimeLayout = "2006-01-02T15:04:05"
+
+// jsonTrip is the JSON encoding of a Trip passed between the crawler, ingestion and the API. The scrape time is RFC3339,
+// down to the nanosecond, while leg times are the local times at their stops, with no offset. Amounts are in minor
+// units of their currency.
+type jsonTrip struct {
+	Version   int        `json:"version"`
+	Provider  int        `json:"provider"`
+	ScrapedAt string     `json:"scrapedAt,omitempty"`
+	Legs      []jsonLeg  `json:"legs"`
+	Fares     []jsonFare `json:"fares"`
+}
+
+type jsonLeg struct {
+	Departure     string `json:"departure"`
+	Arrival       string `json:"arrival"`
+	DepartureTime string `json:"departureTime"`
+	ArrivalTime   string `json:"arrivalTime"`
+	Id            string `json:"id"`
+}
+
+type jsonFare struct {
+	Type          string                      `json:"type"`
+	Price         jsonMoney                   `json:"price"`
+	Prices        map[PassengerType]jsonMoney `json:"prices,omitempty"`
+	Total         jsonMoney                   `json:"total"`
+	TaxesIncluded bool                        `json:"taxesIncluded"`
+}
+
+type jsonMoney struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (t *Trip) MarshalJSON() ([]byte, error) {
+	jt := jsonTrip{
+		Version:  TripSchemaVersion,
+		Provider: t.Provider,
+		Legs:     make([]jsonLeg, 0, len(t.Legs)),
+		Fares:    make([]jsonFare, 0, len(t.Fares)),
+	}
+	if !t.ScrapedAt.IsZero() {
+		jt.ScrapedAt = t.ScrapedAt.Format(time.RFC3339Nano)
+	}
+	for _, l := range t.Legs {
+		jt.Legs = append(jt.Legs, jsonLeg{
+			Departure:     l.Dep,
+			Arrival:       l.Arr,
+			DepartureTime: l.DepTime.Format(legTimeLayout),
+			ArrivalTime:   l.ArrTime.Format(legTimeLayout),
+			Id:            l.Id,
+		})
+	}
+	for _, f := range t.Fares {
+		jf := jsonFare{
+			Type:          f.Type,
+			Price:         jsonMoney{f.Price.Amount, f.Price.Currency},
+			Total:         jsonMoney{f.Total.Amount, f.Total.Currency},
+			TaxesIncluded: f.TaxesIncluded,
+		}
+		if len(f.Prices) > 0 {
+			jf.Prices = make(map[PassengerType]jsonMoney)
+			for pt, p := range f.Prices {
+				jf.Prices[pt] = jsonMoney{p.Amount, p.Currency}
+			}
+		}
+		jt.Fares = append(jt.Fares, jf)
+	}
+	return json.Marshal(jt)
+}
+
+// UnmarshalJSON reads a trip encoded with MarshalJSON. Trips encoded with a newer schema version are rejected. Leg
+// times are read as wall-clock times kept in UTC, like the ones of every scraper.
+func (t *Trip) UnmarshalJSON(data []byte) error {
+	var jt jsonTrip
+	err := json.Unmarshal(data, &jt)
+	if err != nil {
+		return err
+	}
+	if jt.Version < 1 || jt.Version > TripSchemaVersion {
+		return fmt.Errorf("Unsupported trip schema version %d", jt.Version)
+	}
+
+	decoded := Trip{
+		Provider: jt.Provider,
+		Legs:     make([]*Leg, 0, len(jt.Legs)),
+		Fares:    make([]*Fare, 0, len(jt.Fares)),
+	}
+	if jt.ScrapedAt != "" {
+		decoded.ScrapedAt, err = time.Parse(time.RFC3339Nano, jt.ScrapedAt)
+		if err != nil {
+			return err
+		}
+	}
+	for _, jl := range jt.Legs {
+		depTime, err := parseLegTime(jl.DepartureTime, jt.Version)
+		if err != nil {
+			return err
+		}
+		arrTime, err := parseLegTime(jl.ArrivalTime, jt.Version)
+		if err != nil {
+			return err
+		}
+		decoded.Legs = append(decoded.Legs, newLeg(jl.Departure, jl.Arrival, jl.Id, depTime, arrTime))
+	}
+	for _, jf := range jt.Fares {
+		f := &Fare{
+			Type:          jf.Type,
+			Price:         money.New(jf.Price.Amount, jf.Price.Currency),
+			Total:         money.New(jf.Total.Amount, jf.Total.Currency),
+			TaxesIncluded: jf.TaxesIncluded,
+		}
+		if len(jf.Prices) > 0 {
+			f.Prices = make(map[PassengerType]money.Money)
+			for pt, p := range jf.Prices {
+				f.Prices[pt] = money.New(p.Amount, p.Currency)
+			}
+		}
+		decoded.Fares = append(decoded.Fares, f)
+	}
+	*t = decoded
+	return nil
+}
+
+// parseLegTime reads a leg time encoded with schema version. The offset version 1 carried is dropped, keeping the wall
+// clock.
+func parseLegTime(s string, version int) (time.Time, error) {
+	if version > 1 {
+		return time.Parse(legTimeLayout, s)
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), nil
+}
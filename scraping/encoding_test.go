@@ -0,0 +1,84 @@
+package scraping
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+)
+
+func TestTripJSON(t *testing.T) {
+	party := newPartyFare("standard", map[PassengerType]money.Money{Adult: money.New(15818, "USD"), Child: money.New(15818, "USD")}, newPassengers(1, 1, 0), true)
+	trip := &Trip{
+		Fares: []*Fare{party, adultFare("9Dollar", 122.08, true)},
+		Legs: []*Leg{
+			&Leg{Dep: "BOS", Arr: "BWI", Id: "NK2025", DepTime: time.Date(2019, time.Month(9), 13, 7, 45, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 9, 24, 0, 0, time.UTC)},
+		},
+		Provider:  0,
+		ScrapedAt: time.Date(2019, time.Month(9), 1, 12, 30, 0, 0, time.UTC),
+	}
+
+	data, err := json.Marshal(trip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"version":2,"provider":0,"scrapedAt":"2019-09-01T12:30:00Z",` +
+		`"legs":[{"departure":"BOS","arrival":"BWI","departureTime":"2019-09-13T07:45:00","arrivalTime":"2019-09-13T09:24:00","id":"NK2025"}],` +
+		`"fares":[{"type":"standard","price":{"amount":15818,"currency":"USD"},"prices":{"adult":{"amount":15818,"currency":"USD"},"child":{"amount":15818,"currency":"USD"}},"total":{"amount":31636,"currency":"USD"},"taxesIncluded":true},` +
+		`{"type":"9Dollar","price":{"amount":12208,"currency":"USD"},"prices":{"adult":{"amount":12208,"currency":"USD"}},"total":{"amount":12208,"currency":"USD"},"taxesIncluded":true}]}`
+	if string(data) != expected {
+		t.Errorf("Encoding differs. Want \n%s, \ngot \n%s", expected, data)
+	}
+
+	var decoded Trip
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTrips(t, []*Trip{trip}, []*Trip{&decoded})
+	if decoded.Provider != trip.Provider || !decoded.ScrapedAt.Equal(trip.ScrapedAt) {
+		t.Errorf("Provider or scrape time differ. Want %d at %v, got %d at %v", trip.Provider, trip.ScrapedAt, decoded.Provider, decoded.ScrapedAt)
+	}
+
+	// Version 1 leg times carried an offset, dropped keeping the wall clock.
+	v1 := `{"version":1,"provider":0,"legs":[{"departure":"BOS","arrival":"BWI","departureTime":"2019-09-13T07:45:00-04:00","arrivalTime":"2019-09-13T09:24:00Z","id":"NK2025"}],"fares":[]}`
+	err = json.Unmarshal([]byte(v1), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := decoded.Legs[0]; !l.DepTime.Equal(trip.Legs[0].DepTime) || !l.ArrTime.Equal(trip.Legs[0].ArrTime) {
+		t.Errorf("Expected the wall clock of version 1 leg times, got %v", l)
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":3,"legs":[],"fares":[]}`), &decoded); err == nil {
+		t.Error("Expected an error decoding a newer schema version")
+	}
+}
+
+func TestTripsJSONRoundTrip(t *testing.T) {
+	sc := newMegabusScraper()
+	sc.client.Transport = newCassetteTransport(t, "megabus")
+	trips, err := sc.GetTrips("123", "289", 8, 9, 2019, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, trip := range trips {
+		trip.Provider, trip.ScrapedAt = 1, time.Date(2019, time.Month(9), 1, 12, 30, 15, 123456789, time.UTC)
+	}
+	data, err := json.Marshal(trips)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []*Trip
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTrips(t, trips, decoded)
+	for i, trip := range decoded {
+		if trip.Provider != 1 || !trip.ScrapedAt.Equal(trips[i].ScrapedAt) {
+			t.Errorf("Trip %d: expected provider 1 scraped at %v, got %d at %v", i, trips[i].ScrapedAt, trip.Provider, trip.ScrapedAt)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package scraping
+
+import (
+	"sort"
+	"time"
+)
+
+// Filter tells whether a trip is to be kept.
+type Filter func(t *Trip) bool
+
+// MaxLayovers keeps the trips stopping over at most n times.
+func MaxLayovers(n int) Filter {
+	return func(t *Trip) bool {
+		return t.Transfers() <= n
+	}
+}
+
+func MaxDuration(d time.Duration, z Zones) Filter {
+	return func(t *Trip) bool {
+		return t.Duration(z) <= d
+	}
+}
+
+// DepartingBetween keeps the trips departing within a window of the day, given as the time since midnight, both ends
+// included. Windows ending before they start wrap around midnight.
+func DepartingBetween(from, to time.Duration) Filter {
+	return func(t *Trip) bool {
+		dep := t.Departure()
+		clock := time.Duration(dep.Hour())*time.Hour + time.Duration(dep.Minute())*time.Minute
+		if from <= to {
+			return from <= clock && clock <= to
+		}
+		return from <= clock || clock <= to
+	}
+}
+
+func WithFareType(fareType string) Filter {
+	return func(t *Trip) bool {
+		return t.getFare(fareType) != nil
+	}
+}
+
+// FilterTrips returns the trips passing every filter.
+func FilterTrips(trips []*Trip, filters ...Filter) []*Trip {
+	kept := make([]*Trip, 0, len(trips))
+trips:
+	for _, t := range trips {
+		for _, f := range filters {
+			if !f(t) {
+				continue trips
+			}
+		}
+		kept = append(kept, t)
+	}
+	return kept
+}
+
+// Less orders two trips.
+type Less func(t1, t2 *Trip) bool
+
+// ByPrice sorts trips by their cheapest fare, the trips with no fares last.
+func ByPrice(t1, t2 *Trip) bool {
+	f1, f2 := t1.Cheapest(), t2.Cheapest()
+	if f1 == nil || f2 == nil {
+		return f1 != nil
+	}
+	cmp, err := f1.Total.Cmp(f2.Total)
+	return err == nil && cmp < 0
+}
+
+func ByDeparture(t1, t2 *Trip) bool {
+	return t1.Departure().Before(t2.Departure())
+}
+
+func ByDuration(z Zones) Less {
+	return func(t1, t2 *Trip) bool {
+		return t1.Duration(z) < t2.Duration(z)
+	}
+}
+
+// SortTrips sorts trips in place by the first of less, then the next ones to break ties, keeping the order of the trips
+// still tied.
+func SortTrips(trips []*Trip, less ...Less) {
+	sort.SliceStable(trips, func(i, j int) bool {
+		for _, l := range less {
+			switch {
+			case l(trips[i], trips[j]):
+				return true
+			case l(trips[j], trips[i]):
+				return false
+			}
+		}
+		return false
+	})
+}
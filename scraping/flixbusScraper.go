@@ -0,0 +1,131 @@
+package scraping
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	flixbusRequestInterval = time.Millisecond * 200
+	flixbusCurrency        = "USD"
+)
+
+var flixbusSearchUrl = template.Must(template.New("flixbus").Funcs(template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+}).Parse(`https://global.api.flixbus.com/public/v1/trip/search.json?search_by=cities&from={{.Departure}}&to={{.Arrival}}&departure_date={{.Date.Format "02.01.2006"}}&adult={{.Adults}}&children={{add .Children .Infants}}&bikes=0&currency={{.Currency}}`))
+
+type JsonFbSearch struct {
+	Trips []JsonFbTrip `json:"trips"`
+}
+
+type JsonFbTrip struct {
+	Items []JsonFbItem `json:"items"`
+}
+
+type JsonFbItem struct {
+	Uid           string      `json:"uid"`
+	PriceTotalSum float64     `json:"price_total_sum"`
+	Legs          []JsonFbLeg `json:"legs"`
+}
+
+type JsonFbLeg struct {
+	Departure JsonFbStop `json:"departure"`
+	Arrival   JsonFbStop `json:"arrival"`
+	Line      struct {
+		Code string `json:"code"`
+	} `json:"line"`
+}
+
+type JsonFbStop struct {
+	CityId    int    `json:"city_id"`
+	Timestamp int64  `json:"timestamp"`
+	Tz        string `json:"tz"`
+}
+
+// NewFlixbusScraper creates a scraper for FlixBus, whose city ids are given by stopIds for every stop of ours
+// FlixBus serves. FlixBus lists the price for the whole party, taxes included, and has a single fare type.
+func NewFlixbusScraper(stopIds map[string]string) *JsonApiScraper {
+	return NewJsonApiScraper(JsonApiConfig{
+		Name:            "FlixBus",
+		SearchUrl:       flixbusSearchUrl,
+		Decode:          decodeFlixbusSearch,
+		Currency:        flixbusCurrency,
+		TaxesIncluded:   true,
+		StopIds:         stopIds,
+		RequestInterval: flixbusRequestInterval,
+		MaxPages:        1,
+	})
+}
+
+func decodeFlixbusSearch(body []byte) (*JsonApiPage, error) {
+	var search JsonFbSearch
+	err := json.Unmarshal(body, &search)
+	if err != nil {
+		return nil, err
+	}
+	page := &JsonApiPage{}
+	for _, t := range search.Trips {
+		for _, item := range t.Items {
+			trip := JsonApiTrip{
+				Fares: []JsonApiFare{{Type: "standard", Total: item.PriceTotalSum}},
+			}
+			for _, l := range item.Legs {
+				depTime, err := flixbusTime(l.Departure)
+				if err != nil {
+					return nil, err
+				}
+				arrTime, err := flixbusTime(l.Arrival)
+				if err != nil {
+					return nil, err
+				}
+				trip.Legs = append(trip.Legs, JsonApiLeg{
+					Dep:     strconv.Itoa(l.Departure.CityId),
+					Arr:     strconv.Itoa(l.Arrival.CityId),
+					DepTime: depTime,
+					ArrTime: arrTime,
+					Id:      l.Line.Code,
+				})
+			}
+			page.Trips = append(page.Trips, trip)
+		}
+	}
+	return page, nil
+}
+
+// flixbusTime returns the local wall-clock time of a stop, kept in UTC like the times of every other scraper.
+func flixbusTime(s JsonFbStop) (time.Time, error) {
+	offset, err := parseGmtOffset(s.Tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	local := time.Unix(s.Timestamp, 0).In(time.FixedZone(s.Tz, offset))
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), 0, 0, time.UTC), nil
+}
+
+// parseGmtOffset reads offsets such as "GMT-04:00" into seconds east of UTC.
+func parseGmtOffset(tz string) (int, error) {
+	s := strings.TrimPrefix(tz, "GMT")
+	if s == "" {
+		return 0, nil
+	}
+	sign := 1
+	switch s[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, fmt.Errorf("Unknown time zone %q", tz)
+	}
+	if !strings.Contains(s, ":") {
+		return 0, fmt.Errorf("Unknown time zone %q", tz)
+	}
+	hour, min, err := getHourMinFromTimeString(s[1:])
+	if err != nil {
+		return 0, fmt.Errorf("Unknown time zone %q", tz)
+	}
+	return sign * (hour*3600 + min*60), nil
+}
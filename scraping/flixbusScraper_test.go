@@ -0,0 +1,28 @@
+package scraping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetTripsFlixbus(t *testing.T) {
+	sc := NewFlixbusScraper(map[string]string{"123": "1226", "127": "3428", "143": "3468"})
+	sc.client.Transport = newCassetteTransport(t, "flixbus")
+	expectedTrips := []*Trip{
+		&Trip{
+			Fares: []*Fare{adultFare("standard", 19.99, true)},
+			Legs:  []*Leg{&Leg{Dep: "123", Arr: "143", Id: "2211", DepTime: time.Date(2019, time.Month(9), 8, 8, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 11, 15, 0, 0, time.UTC)}},
+		},
+		&Trip{
+			Fares: []*Fare{adultFare("standard", 24.98, true)},
+			Legs: []*Leg{&Leg{Dep: "123", Arr: "127", Id: "2230", DepTime: time.Date(2019, time.Month(9), 8, 10, 30, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 12, 20, 0, 0, time.UTC)},
+				&Leg{Dep: "127", Arr: "143", Id: "2250", DepTime: time.Date(2019, time.Month(9), 8, 13, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 15, 10, 0, 0, time.UTC)},
+			},
+		},
+	}
+	trips, err := sc.GetTrips("123", "143", 8, 9, 2019, 1, 0, 0)
+	if err != nil {
+		t.Fatalf("Couldn't retrieve trips.\n%v", err)
+	}
+	checkTrips(t, expectedTrips, trips)
+}
@@ -0,0 +1,101 @@
+package scraping
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/time/rate"
+)
+
+const (
+	frontierRequestInterval = time.Second
+	frontierDateLayout      = "Jan 02, 2006"
+	frontierCurrency        = "USD"
+)
+
+var frontierItineraries = &itineraryExtractor{
+	rowsSelector:        "#journey%d .flight-row",
+	fareColumnsSelector: ".fare-headers .fare-header",
+	fareTypes: map[string]string{
+		"Discount Den": "discountDen",
+		"Standard":     "standard",
+	},
+	defaultFareColumns: []fareColumn{
+		{"standard", ".fare-standard .price"},
+	},
+	flightsSelector:       ".flight-details .segment",
+	flightNumbersSelector: ".flight-details .segment-flight",
+	timesSelector:         ".segment-time",
+	depTimeIndex:          0,
+	arrTimeIndex:          1,
+	stationsSelector:      ".segment-station",
+	carrierPrefix:         "F9",
+	timeLayout:            "3:04pm",
+	currency:              frontierCurrency,
+	fare:                  airlineFare,
+}
+
+type FrontierScraper struct {
+	client  http.Client
+	limiter *rate.Limiter
+}
+
+func NewFrontierScraper() *FrontierScraper {
+	return &FrontierScraper{
+		client:  http.Client{},
+		limiter: rate.NewLimiter(rate.Every(frontierRequestInterval), 1),
+	}
+}
+
+func (sc *FrontierScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
+	return sc.GetTripsContext(context.Background(), departure, arrival, day, month, year, adults, children, infants)
+}
+
+func (sc *FrontierScraper) GetTripsContext(ctx context.Context, departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	query := url.Values{
+		"o1":  {departure},
+		"d1":  {arrival},
+		"dd1": {date.Format(frontierDateLayout)},
+		"ADT": {strconv.Itoa(adults)},
+		"CHD": {strconv.Itoa(children)},
+		"inl": {strconv.Itoa(infants)},
+		"mon": {"true"},
+	}
+	body, err := sc.get(ctx, "https://booking.flyfrontier.com/Flight/InternalSelect?"+query.Encode())
+	if err != nil {
+		return []*Trip{}, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return []*Trip{}, err
+	}
+	return frontierItineraries.trips(doc.Selection, 1, date, newPassengers(adults, children, infants)), nil
+}
+
+func (sc *FrontierScraper) get(ctx context.Context, url string) ([]byte, error) {
+	err := sc.limiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sc.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Frontier. Search returned status %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
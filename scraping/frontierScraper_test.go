@@ -0,0 +1,127 @@
+package scraping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jcasado94/connecc/providers"
+)
+
+func TestGetTripsFrontier(t *testing.T) {
+	sc := NewFrontierScraper()
+	sc.client.Transport = newCassetteTransport(t, "frontier")
+	expectedTrips := []*Trip{
+		&Trip{
+			Fares: []*Fare{adultFare("discountDen", 59.0, true), adultFare("standard", 79.0, true), adultFare("theWorks", 139.0, true)},
+			Legs:  []*Leg{&Leg{Dep: "DEN", Arr: "LAS", Id: "F91297", DepTime: time.Date(2019, time.Month(9), 13, 6, 5, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 7, 10, 0, 0, time.UTC)}},
+		},
+		&Trip{
+			Fares: []*Fare{adultFare("standard", 1024.40, true), adultFare("theWorks", 1105.40, true)},
+			Legs: []*Leg{&Leg{Dep: "DEN", Arr: "PHX", Id: "F9641", DepTime: time.Date(2019, time.Month(9), 13, 14, 20, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 16, 5, 0, 0, time.UTC)},
+				&Leg{Dep: "PHX", Arr: "LAS", Id: "F91331", DepTime: time.Date(2019, time.Month(9), 13, 17, 15, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 17, 30, 0, 0, time.UTC)},
+			},
+		},
+		&Trip{
+			Fares: []*Fare{adultFare("discountDen", 39.0, true), adultFare("standard", 49.0, true), adultFare("theWorks", 109.0, true)},
+			Legs:  []*Leg{&Leg{Dep: "DEN", Arr: "LAS", Id: "F91299", DepTime: time.Date(2019, time.Month(9), 13, 23, 45, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 14, 0, 50, 0, 0, time.UTC)}},
+		},
+	}
+	trips, err := sc.GetTrips("DEN", "LAS", 13, 9, 2019, 1, 0, 0)
+	if err != nil {
+		t.Fatalf("Couldn't retrieve trips.\n%v", err)
+	}
+	checkTrips(t, expectedTrips, trips)
+}
+
+func TestProviderScraperFareTypes(t *testing.T) {
+	c, err := providers.Parse([]byte(`{"frontier": {"id": 4, "mode": "air", "currency": "USD", "fareTypes": ["discountDen", "standard"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := c.ByName("frontier")
+	sc, err := NewProviderScraper(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.(*providerScraper).scraper.(*FrontierScraper).client.Transport = newCassetteTransport(t, "frontier")
+	// theWorks isn't listed, so only its fares are dropped.
+	expectedTrips := []*Trip{
+		&Trip{
+			Fares: []*Fare{adultFare("discountDen", 59.0, true), adultFare("standard", 79.0, true)},
+			Legs:  []*Leg{&Leg{Dep: "DEN", Arr: "LAS", Id: "F91297", DepTime: time.Date(2019, time.Month(9), 13, 6, 5, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 7, 10, 0, 0, time.UTC)}},
+		},
+		&Trip{
+			Fares: []*Fare{adultFare("standard", 1024.40, true)},
+			Legs: []*Leg{&Leg{Dep: "DEN", Arr: "PHX", Id: "F9641", DepTime: time.Date(2019, time.Month(9), 13, 14, 20, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 16, 5, 0, 0, time.UTC)},
+				&Leg{Dep: "PHX", Arr: "LAS", Id: "F91331", DepTime: time.Date(2019, time.Month(9), 13, 17, 15, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 17, 30, 0, 0, time.UTC)},
+			},
+		},
+		&Trip{
+			Fares: []*Fare{adultFare("discountDen", 39.0, true), adultFare("standard", 49.0, true)},
+			Legs:  []*Leg{&Leg{Dep: "DEN", Arr: "LAS", Id: "F91299", DepTime: time.Date(2019, time.Month(9), 13, 23, 45, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 14, 0, 50, 0, 0, time.UTC)}},
+		},
+	}
+	trips, err := sc.GetTrips("DEN", "LAS", 13, 9, 2019, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTrips(t, expectedTrips, trips)
+}
+
+// staticScraper returns trips on every search.
+type staticScraper struct {
+	trips []*Trip
+}
+
+func (sc *staticScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
+	return sc.trips, nil
+}
+
+func TestProviderScraperFarelessTrips(t *testing.T) {
+	c, err := providers.Parse([]byte(`{"frontier": {"id": 4, "mode": "air", "currency": "USD", "fareTypes": ["standard"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := c.ByName("frontier")
+	leg := &Leg{Dep: "DEN", Arr: "LAS", Id: "F91297", DepTime: time.Date(2019, time.Month(9), 13, 6, 5, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 7, 10, 0, 0, time.UTC)}
+	sc := &providerScraper{
+		scraper: &staticScraper{[]*Trip{
+			newTrip([]*Fare{adultFare("theWorks", 99, true)}, []*Leg{leg}),
+			newTrip([]*Fare{}, []*Leg{leg}),
+		}},
+		provider: p,
+	}
+	// Trips left or found without fares are kept for validation to reject.
+	trips, err := sc.GetTrips("DEN", "LAS", 13, 9, 2019, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTrips(t, []*Trip{newTrip([]*Fare{}, []*Leg{leg}), newTrip([]*Fare{}, []*Leg{leg})}, trips)
+	for _, trip := range trips {
+		if trip.Provider != 4 || trip.ScrapedAt.IsZero() {
+			t.Errorf("Expected the trip stamped with provider 4, got %d at %v", trip.Provider, trip.ScrapedAt)
+		}
+	}
+}
+
+func TestProviderScraperCurrency(t *testing.T) {
+	c, err := providers.Parse([]byte(`{
+		"frontier": {"id": 4, "mode": "air", "currency": "CAD", "fareTypes": ["standard"]},
+		"flixbus": {"id": 2, "mode": "bus", "currency": "CAD", "fareTypes": ["standard"]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := c.ByName("frontier")
+	if _, err := NewProviderScraper(p); err == nil {
+		t.Error("Expected Frontier, which prices in USD, to be rejected in CAD")
+	}
+	p, _ = c.ByName("flixbus")
+	sc, err := NewProviderScraper(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if currency := sc.(*providerScraper).scraper.(*JsonApiScraper).config.Currency; currency != "CAD" {
+		t.Errorf("Expected FlixBus to price in CAD, got %s", currency)
+	}
+}
@@ -0,0 +1,156 @@
+package scraping
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jcasado94/connecc/money"
+)
+
+// itineraryExtractor parses the results page of an airline website listing each option of a search in a row, its
+// fares in columns and its flights in a details section.
+type itineraryExtractor struct {
+	// rowsSelector selects the options of the n-th segment of a search once formatted with n, as in ".rowsMarket%d".
+	rowsSelector string
+	// fareColumnsSelector selects the headers of the fare columns. The name attribute of a header names its fare and
+	// its data-selector attribute selects the price within a row.
+	fareColumnsSelector string
+	// fareTypes maps column names to fare types. Other columns get their name in lower camel case.
+	fareTypes map[string]string
+	// defaultFareColumns are used when the page has no fare column headers.
+	defaultFareColumns []fareColumn
+	flightsSelector    string
+	// flightNumbersSelector selects the flight number of each flight of a row, in the same order as flightsSelector.
+	flightNumbersSelector string
+	// timesSelector selects the departure and arrival times within a flight, at depTimeIndex and arrTimeIndex.
+	timesSelector              string
+	depTimeIndex, arrTimeIndex int
+	// stationsSelector selects the departure and arrival stations within a flight, in that order.
+	stationsSelector string
+	// carrierPrefix is prepended to flight numbers, as in "NK" for "NK2025".
+	carrierPrefix string
+	// timeLayout parses departure and arrival times, as in "3:04 PM".
+	timeLayout string
+	currency   string
+	// fare builds the fare of the party from the price listed in a column.
+	fare func(fareType string, price money.Money, passengers Passengers) *Fare
+}
+
+type fareColumn struct {
+	fareType, selector string
+}
+
+// trips parses the options listed in dom for the market-th segment of the search, departing on date.
+func (e *itineraryExtractor) trips(dom *goquery.Selection, market int, date time.Time, passengers Passengers) []*Trip {
+	trips := make([]*Trip, 0)
+	columns := e.fareColumns(dom)
+	dom.Find(fmt.Sprintf(e.rowsSelector, market)).Each(func(_ int, s *goquery.Selection) {
+		trip := Trip{}
+		trip.Fares = e.fares(s, columns, passengers)
+		trip.Legs = e.legs(s, date)
+
+		trips = append(trips, &trip)
+	})
+	return trips
+}
+
+// fareColumns reads the fare columns (member fares, standard, bundles...) listed in the header of the page.
+func (e *itineraryExtractor) fareColumns(dom *goquery.Selection) []fareColumn {
+	columns := make([]fareColumn, 0)
+	seen := make(map[string]bool)
+	dom.Find(e.fareColumnsSelector).Each(func(_ int, s *goquery.Selection) {
+		name, _ := s.Attr("name")
+		selector, ok := s.Attr("data-selector")
+		if !ok || seen[selector] {
+			return
+		}
+		seen[selector] = true
+		columns = append(columns, fareColumn{e.fareType(name), selector})
+	})
+	if len(columns) == 0 {
+		return e.defaultFareColumns
+	}
+	return columns
+}
+
+func (e *itineraryExtractor) fareType(columnName string) string {
+	if fareType, ok := e.fareTypes[columnName]; ok {
+		return fareType
+	}
+	words := strings.Fields(columnName)
+	for i, w := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(w)
+		} else {
+			words[i] = strings.ToUpper(w[:1]) + strings.ToLower(w[1:])
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// fares reads the price of every column of a row, skipping the columns with no price, as when sold out.
+func (e *itineraryExtractor) fares(s *goquery.Selection, columns []fareColumn, passengers Passengers) []*Fare {
+	var fares []*Fare
+	for _, column := range columns {
+		label := s.Find(column.selector).Text()
+		if strings.IndexFunc(label, unicode.IsDigit) < 0 {
+			continue
+		}
+		price, err := money.Parse(label, e.currency)
+		if err != nil {
+			return nil
+		}
+		fares = append(fares, e.fare(column.fareType, price, passengers))
+	}
+
+	return fares
+}
+
+// legs reads the flights of a row departing on date. Each flight departs after the arrival of the previous one, so
+// times earlier than it belong to the next day.
+func (e *itineraryExtractor) legs(s *goquery.Selection, date time.Time) []*Leg {
+	var legs []*Leg
+	sFlightNumbers := s.Find(e.flightNumbersSelector)
+	var arrTime, depTime time.Time
+	s.Find(e.flightsSelector).Each(func(i int, s *goquery.Selection) {
+		fieldsTimes := s.Find(e.timesSelector)
+		dep, err := time.Parse(e.timeLayout, strings.TrimSpace(fieldsTimes.Eq(e.depTimeIndex).Text()))
+		if err != nil {
+			return
+		}
+		if arrTime.IsZero() {
+			depTime = time.Date(date.Year(), date.Month(), date.Day(), dep.Hour(), dep.Minute(), 0, 0, time.UTC)
+		} else {
+			depTime = processDayDifference(&arrTime, dep.Hour(), dep.Minute())
+		}
+		arr, err := time.Parse(e.timeLayout, strings.TrimSpace(fieldsTimes.Eq(e.arrTimeIndex).Text()))
+		if err != nil {
+			return
+		}
+		arrTime = processDayDifference(&depTime, arr.Hour(), arr.Minute())
+
+		fieldsStations := s.Find(e.stationsSelector)
+		depStation := strings.TrimSpace(fieldsStations.Eq(0).Text())
+		arrStation := strings.TrimSpace(fieldsStations.Eq(1).Text())
+
+		flightNumberSlice := strings.Fields(sFlightNumbers.Eq(i).Text())
+		flightNumber := e.carrierPrefix
+		if len(flightNumberSlice) > 0 {
+			flightNumber += flightNumberSlice[len(flightNumberSlice)-1]
+		}
+
+		legs = append(legs, newLeg(depStation, arrStation, flightNumber, depTime, arrTime))
+	})
+
+	return legs
+}
+
+// airlineFare builds the fare of a party from the price an airline lists, which is per traveller and includes taxes,
+// as US airlines must advertise. Children pay the same as adults and lap infants fly free.
+func airlineFare(fareType string, price money.Money, passengers Passengers) *Fare {
+	prices := map[PassengerType]money.Money{Adult: price, Child: price, Infant: money.New(0, price.Currency)}
+	return newPartyFare(fareType, prices, passengers, true)
+}
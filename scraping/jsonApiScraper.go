@@ -0,0 +1,207 @@
+package scraping
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+	"golang.org/x/time/rate"
+)
+
+const defaultJsonApiMaxPages = 10
+
+// JsonApiConfig describes a provider whose search results come from a JSON API, so that it can be scraped by a
+// JsonApiScraper without writing the requests, pagination and Trip building again.
+type JsonApiConfig struct {
+	Name string
+	// SearchUrl is rendered with a JsonApiQuery to build the URL of every results page.
+	SearchUrl *template.Template
+	// SearchBody, if set, builds the value sent, encoded as JSON, as the body of a POST instead of a GET.
+	SearchBody func(query *JsonApiQuery) interface{}
+	// Decode reads a page of results.
+	Decode   func(body []byte) (*JsonApiPage, error)
+	Currency string
+	// TaxesIncluded tells whether the prices returned by the API include taxes and fees.
+	TaxesIncluded bool
+	// StopIds maps our stop ids to the provider's ones. Stops missing from it are sent as they are.
+	StopIds         map[string]string
+	RequestInterval time.Duration
+	// MaxPages bounds how many results pages are requested per search. defaultJsonApiMaxPages is used if 0.
+	MaxPages int
+}
+
+// JsonApiQuery holds the values available to the SearchUrl template. Departure and Arrival are provider stop ids.
+type JsonApiQuery struct {
+	Departure, Arrival        string
+	Date                      time.Time
+	Adults, Children, Infants int
+	Page                      int
+	Currency                  string
+}
+
+// JsonApiPage is a page of results decoded from the provider's response.
+type JsonApiPage struct {
+	Trips    []JsonApiTrip
+	NextPage bool
+}
+
+type JsonApiTrip struct {
+	Legs  []JsonApiLeg
+	Fares []JsonApiFare
+}
+
+// JsonApiLeg holds provider stop ids, translated back to ours when building the Leg.
+type JsonApiLeg struct {
+	Dep, Arr         string
+	DepTime, ArrTime time.Time
+	Id               string
+}
+
+// JsonApiFare is priced either per passenger type, through Prices, or for the whole party, through Total, in which
+// case every traveller is assumed to pay the same.
+type JsonApiFare struct {
+	Type   string
+	Prices map[PassengerType]float64
+	Total  float64
+}
+
+type JsonApiScraper struct {
+	config  JsonApiConfig
+	client  http.Client
+	limiter *rate.Limiter
+	ourIds  map[string]string
+}
+
+func NewJsonApiScraper(config JsonApiConfig) *JsonApiScraper {
+	if config.MaxPages == 0 {
+		config.MaxPages = defaultJsonApiMaxPages
+	}
+	limit := rate.Inf
+	if config.RequestInterval > 0 {
+		limit = rate.Every(config.RequestInterval)
+	}
+	ourIds := make(map[string]string)
+	for ours, theirs := range config.StopIds {
+		ourIds[theirs] = ours
+	}
+	return &JsonApiScraper{
+		config:  config,
+		client:  http.Client{},
+		limiter: rate.NewLimiter(limit, 1),
+		ourIds:  ourIds,
+	}
+}
+
+func (sc *JsonApiScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
+	return sc.GetTripsContext(context.Background(), departure, arrival, day, month, year, adults, children, infants)
+}
+
+// GetTripsContext requests results pages until the provider reports no more of them or MaxPages is reached.
+func (sc *JsonApiScraper) GetTripsContext(ctx context.Context, departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
+	query := JsonApiQuery{
+		Departure: sc.providerId(departure),
+		Arrival:   sc.providerId(arrival),
+		Date:      time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC),
+		Adults:    adults,
+		Children:  children,
+		Infants:   infants,
+		Currency:  sc.config.Currency,
+	}
+	passengers := newPassengers(adults, children, infants)
+
+	trips := make([]*Trip, 0)
+	for query.Page = 1; query.Page <= sc.config.MaxPages; query.Page++ {
+		page, err := sc.getPage(ctx, &query)
+		if err != nil {
+			return []*Trip{}, err
+		}
+		for i := range page.Trips {
+			trips = append(trips, sc.buildTrip(&page.Trips[i], passengers))
+		}
+		if !page.NextPage {
+			break
+		}
+	}
+	return trips, nil
+}
+
+func (sc *JsonApiScraper) getPage(ctx context.Context, query *JsonApiQuery) (*JsonApiPage, error) {
+	var url bytes.Buffer
+	err := sc.config.SearchUrl.Execute(&url, query)
+	if err != nil {
+		return nil, err
+	}
+	method, contentType := http.MethodGet, ""
+	var body []byte
+	if sc.config.SearchBody != nil {
+		method, contentType = http.MethodPost, "application/json"
+		body, err = json.Marshal(sc.config.SearchBody(query))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = sc.limiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, url.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := sc.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s. Search returned status %d: %s", sc.config.Name, resp.StatusCode, respBody)
+	}
+	return sc.config.Decode(respBody)
+}
+
+func (sc *JsonApiScraper) buildTrip(jt *JsonApiTrip, passengers Passengers) *Trip {
+	legs := make([]*Leg, 0, len(jt.Legs))
+	for _, jl := range jt.Legs {
+		legs = append(legs, newLeg(sc.ourId(jl.Dep), sc.ourId(jl.Arr), jl.Id, jl.DepTime, jl.ArrTime))
+	}
+	fares := make([]*Fare, 0, len(jt.Fares))
+	for _, jf := range jt.Fares {
+		if jf.Prices == nil {
+			fares = append(fares, newEvenPartyFare(jf.Type, money.FromFloat(jf.Total, sc.config.Currency), passengers, sc.config.TaxesIncluded))
+			continue
+		}
+		prices := make(map[PassengerType]money.Money)
+		for pt, p := range jf.Prices {
+			prices[pt] = money.FromFloat(p, sc.config.Currency)
+		}
+		fares = append(fares, newPartyFare(jf.Type, prices, passengers, sc.config.TaxesIncluded))
+	}
+	return newTrip(fares, legs)
+}
+
+func (sc *JsonApiScraper) providerId(id string) string {
+	if theirs, ok := sc.config.StopIds[id]; ok {
+		return theirs
+	}
+	return id
+}
+
+func (sc *JsonApiScraper) ourId(id string) string {
+	if ours, ok := sc.ourIds[id]; ok {
+		return ours
+	}
+	return id
+}
@@ -0,0 +1,81 @@
+package scraping
+
+import (
+	"encoding/json"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+)
+
+type jsonTestPage struct {
+	Results []struct {
+		Id, From, To     string
+		Departs, Arrives time.Time
+		Adult, Child     float64
+	}
+	More bool
+}
+
+func newJsonTestScraper(t *testing.T, maxPages int) *JsonApiScraper {
+	sc := NewJsonApiScraper(JsonApiConfig{
+		Name:      "Test",
+		SearchUrl: template.Must(template.New("test").Parse(`https://api.example.com/search?from={{.Departure}}&to={{.Arrival}}&date={{.Date.Format "2006-01-02"}}&page={{.Page}}`)),
+		Decode: func(body []byte) (*JsonApiPage, error) {
+			var p jsonTestPage
+			err := json.Unmarshal(body, &p)
+			if err != nil {
+				return nil, err
+			}
+			page := &JsonApiPage{NextPage: p.More}
+			for _, r := range p.Results {
+				page.Trips = append(page.Trips, JsonApiTrip{
+					Legs:  []JsonApiLeg{{Dep: r.From, Arr: r.To, DepTime: r.Departs, ArrTime: r.Arrives, Id: r.Id}},
+					Fares: []JsonApiFare{{Type: "standard", Prices: map[PassengerType]float64{Adult: r.Adult, Child: r.Child}}},
+				})
+			}
+			return page, nil
+		},
+		Currency: "USD",
+		StopIds:  map[string]string{"1": "A", "2": "B"},
+		MaxPages: maxPages,
+	})
+	sc.client.Transport = newCassetteTransport(t, "jsonApiPages")
+	return sc
+}
+
+func TestGetTripsJsonApi(t *testing.T) {
+	sc := newJsonTestScraper(t, 0)
+	familyFare := func(adult, child float64) *Fare {
+		a, c := money.FromFloat(adult, "USD"), money.FromFloat(child, "USD")
+		total, _ := a.Add(c)
+		return &Fare{Type: "standard", Price: a, Prices: map[PassengerType]money.Money{Adult: a, Child: c}, Total: total}
+	}
+	expectedTrips := []*Trip{
+		&Trip{
+			Fares: []*Fare{familyFare(30, 15)},
+			Legs:  []*Leg{&Leg{Dep: "1", Arr: "2", Id: "X1", DepTime: time.Date(2019, time.Month(9), 8, 8, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 10, 30, 0, 0, time.UTC)}},
+		},
+		&Trip{
+			Fares: []*Fare{familyFare(20, 10)},
+			Legs:  []*Leg{&Leg{Dep: "1", Arr: "2", Id: "X2", DepTime: time.Date(2019, time.Month(9), 8, 10, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 12, 30, 0, 0, time.UTC)}},
+		},
+		&Trip{
+			Fares: []*Fare{familyFare(10, 5)},
+			Legs:  []*Leg{&Leg{Dep: "1", Arr: "2", Id: "X3", DepTime: time.Date(2019, time.Month(9), 8, 14, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 16, 30, 0, 0, time.UTC)}},
+		},
+	}
+	trips, err := sc.GetTrips("1", "2", 8, 9, 2019, 1, 1, 0)
+	if err != nil {
+		t.Fatalf("Couldn't retrieve trips.\n%v", err)
+	}
+	checkTrips(t, expectedTrips, trips)
+
+	sc = newJsonTestScraper(t, 1)
+	trips, err = sc.GetTrips("1", "2", 8, 9, 2019, 1, 1, 0)
+	if err != nil {
+		t.Fatalf("Couldn't retrieve trips.\n%v", err)
+	}
+	checkTrips(t, expectedTrips[:2], trips)
+}
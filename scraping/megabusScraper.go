@@ -1,6 +1,7 @@
 package scraping
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,90 +10,204 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/jcasado94/connecc/money"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultMegabusWorkers  = 4
+	megabusRequestInterval = time.Millisecond * 200
+	megabusMaxDays         = 7
+	megabusDateTimeLayout  = "2006-01-02T15:04:05"
 )
 
 type MegabusScraper struct {
-	client http.Client
+	client   http.Client
+	limiter  *rate.Limiter
+	workers  int
+	currency string
 }
 
 func newMegabusScraper() *MegabusScraper {
 	return &MegabusScraper{
-		client: http.Client{},
+		client:   http.Client{},
+		limiter:  rate.NewLimiter(rate.Every(megabusRequestInterval), defaultMegabusWorkers),
+		workers:  defaultMegabusWorkers,
+		currency: "USD",
 	}
 }
 
 func (sc *MegabusScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
-	trips := make([]*Trip, 0)
-	var err error
+	return sc.GetTripsContext(context.Background(), departure, arrival, day, month, year, adults, children, infants)
+}
 
-	url := fmt.Sprintf("https://us.megabus.com/journey-planner/journeys?days=1&concessionCount=0&departureDate=%d-%d-%d&destinationId=%s&inboundOtherDisabilityCount=0&inboundPcaCount=0&inboundWheelchairSeated=0&nusCount=0&originId=%s&otherDisabilityCount=0&pcaCount=0&totalPassengers=%d&wheelchairSeated=0",
-		year, month, day, arrival, departure, adults+children+infants)
+// GetTripsContext retrieves the trips for the given day, fetching the itineraries of multi-leg journeys concurrently.
+// Trips are returned in the same order Megabus lists the journeys.
+func (sc *MegabusScraper) GetTripsContext(ctx context.Context, departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	return sc.getTrips(ctx, departure, arrival, date, 1, newPassengers(adults, children, infants))
+}
 
-	resp, err := sc.client.Get(url)
-	if err != nil {
-		return []*Trip{}, err
+func (sc *MegabusScraper) GetTripsRange(departure, arrival string, from, to time.Time, adults, children, infants int) ([]*Trip, error) {
+	return sc.GetTripsRangeContext(context.Background(), departure, arrival, from, to, adults, children, infants)
+}
+
+// GetTripsRangeContext retrieves the trips for every day between from and to, both included, asking the journeys
+// endpoint for up to megabusMaxDays days at once.
+func (sc *MegabusScraper) GetTripsRangeContext(ctx context.Context, departure, arrival string, from, to time.Time, adults, children, infants int) ([]*Trip, error) {
+	days := daysBetween(from, to)
+	trips := make([]*Trip, 0)
+	for i := 0; i < len(days); i += megabusMaxDays {
+		n := len(days) - i
+		if n > megabusMaxDays {
+			n = megabusMaxDays
+		}
+		dayTrips, err := sc.getTrips(ctx, departure, arrival, days[i], n, newPassengers(adults, children, infants))
+		if err != nil {
+			return []*Trip{}, err
+		}
+		trips = append(trips, dayTrips...)
 	}
+	return trips, nil
+}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+func (sc *MegabusScraper) getTrips(ctx context.Context, departure, arrival string, date time.Time, days int, passengers Passengers) ([]*Trip, error) {
+	url := fmt.Sprintf("https://us.megabus.com/journey-planner/journeys?days=%d&concessionCount=0&departureDate=%d-%d-%d&destinationId=%s&inboundOtherDisabilityCount=0&inboundPcaCount=0&inboundWheelchairSeated=0&nusCount=0&originId=%s&otherDisabilityCount=0&pcaCount=0&totalPassengers=%d&wheelchairSeated=0",
+		days, date.Year(), date.Month(), date.Day(), arrival, departure, passengers.count())
+
+	body, err := sc.get(ctx, url)
 	if err != nil {
 		return []*Trip{}, err
 	}
-	document := string(body)
-	js, err := getJourniesJson(document)
+	js, err := getJourniesJson(string(body))
 	if err != nil {
 		return []*Trip{}, err
 	}
 
-	for _, j := range js.Journeys {
-		if len(j.Legs) == 1 {
-			err = sc.getOneLegTrip(&j, trips)
+	trips := make([]*Trip, len(js.Journeys))
+	severalLegs := make([]int, 0)
+	for i := range js.Journeys {
+		if len(js.Journeys[i].Legs) == 1 {
+			trips[i], err = sc.getOneLegTrip(&js.Journeys[i], passengers)
 			if err != nil {
 				return []*Trip{}, err
 			}
 		} else {
-			err = sc.getSeveralLegsTrip(&j, &trips, year, month, day, departure, arrival)
-			if err != nil {
-				return []*Trip{}, err
-			}
+			severalLegs = append(severalLegs, i)
 		}
 	}
 
+	err = sc.getSeveralLegsTrips(ctx, js.Journeys, severalLegs, trips, passengers, departure, arrival)
+	if err != nil {
+		return []*Trip{}, err
+	}
+
 	return trips, nil
 
 }
 
-func (sc *MegabusScraper) getOneLegTrip(j *JsonMbJourney, trips []*Trip) error {
-	depTime, err := time.Parse(time.RFC3339, j.Legs[0].DepartureDateTime)
+func (sc *MegabusScraper) get(ctx context.Context, url string) ([]byte, error) {
+	err := sc.limiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	arrTime, err := time.Parse(time.RFC3339, j.Legs[0].ArrivalDateTime)
+	resp, err := sc.client.Do(req.WithContext(ctx))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	trips = append(trips, newTrip(
-		[]*Fare{newFare("standard", j.Price)},
-		[]*Leg{newLeg(j.Legs[0].Origin.CityId, j.Legs[0].Destination.CityId, "", depTime, arrTime)}))
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
 
-	return nil
+func (sc *MegabusScraper) getOneLegTrip(j *JsonMbJourney, passengers Passengers) (*Trip, error) {
+	depTime, err := time.Parse(megabusDateTimeLayout, j.Legs[0].DepartureDateTime)
+	if err != nil {
+		return nil, err
+	}
+	arrTime, err := time.Parse(megabusDateTimeLayout, j.Legs[0].ArrivalDateTime)
+	if err != nil {
+		return nil, err
+	}
+	return newTrip(
+		[]*Fare{megabusFare(money.FromFloat(j.Price, sc.currency), passengers)},
+		[]*Leg{newLeg(j.Legs[0].Origin.CityId, j.Legs[0].Destination.CityId, "", depTime, arrTime)}), nil
 }
 
-func (sc *MegabusScraper) getSeveralLegsTrip(j *JsonMbJourney, trips *[]*Trip, year, month, day int, departure, arrival string) error {
-	url := fmt.Sprintf("https://us.megabus.com/journey-planner/api/itinerary?journeyId=%s", j.JourneyId)
-	resp, err := sc.client.Get(url)
+// getSeveralLegsTrips fetches the itineraries of journeys[i] for every i in indexes using a pool of sc.workers
+// goroutines, storing each resulting trip in trips[i]. The first error cancels the remaining fetches.
+func (sc *MegabusScraper) getSeveralLegsTrips(ctx context.Context, journeys []JsonMbJourney, indexes []int, trips []*Trip, passengers Passengers, departure, arrival string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := sc.workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(indexes) {
+		workers = len(indexes)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				trip, err := sc.getSeveralLegsTrip(ctx, &journeys[i], passengers, departure, arrival)
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				trips[i] = trip
+			}
+		}()
+	}
+
+feed:
+	for _, i := range indexes {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+func (sc *MegabusScraper) getSeveralLegsTrip(ctx context.Context, j *JsonMbJourney, passengers Passengers, departure, arrival string) (*Trip, error) {
+	date, err := time.Parse(megabusDateTimeLayout, j.DepartureDateTime)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	year, month, day := date.Year(), int(date.Month()), date.Day()
+	url := fmt.Sprintf("https://us.megabus.com/journey-planner/api/itinerary?journeyId=%s", j.JourneyId)
+	body, err := sc.get(ctx, url)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var its JsonMbItineraries
 	err = json.Unmarshal(body, &its)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var dep, arr string
 	var depTime, arrTime time.Time
@@ -130,12 +245,16 @@ func (sc *MegabusScraper) getSeveralLegsTrip(j *JsonMbJourney, trips *[]*Trip, y
 			legs = append(legs, newLeg(dep, arr, "", depTime, arrTime))
 		}
 	}
-	*trips = append(*trips, newTrip(
-		[]*Fare{newFare("standard", j.Price)},
+	return newTrip(
+		[]*Fare{megabusFare(money.FromFloat(j.Price, sc.currency), passengers)},
 		legs,
-	))
+	), nil
+}
 
-	return nil
+// megabusFare builds the fare of a journey. Megabus lists the price for the whole party, charges every passenger
+// type alike and adds its booking fee at checkout.
+func megabusFare(total money.Money, passengers Passengers) *Fare {
+	return newEvenPartyFare("standard", total, passengers, false)
 }
 
 func getHourMinFromTimeString(time string) (hour, min int, err error) {
@@ -4,44 +4,48 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/jcasado94/connecc/money"
+	"github.com/jcasado94/connecc/scraping/replay"
+	"golang.org/x/time/rate"
 )
 
 func TestGetTripsMegabus(t *testing.T) {
 	sc := newMegabusScraper()
-	sc.client.Transport = newMultipleMockRoundTripper(urlToFilePath(), urlToContentType())
+	sc.client.Transport = newCassetteTransport(t, "megabus")
 	expectedTrips := []*Trip{
 		&Trip{
-			Fares: []*Fare{&Fare{Price: 99.0, Type: "standard"}},
+			Fares: []*Fare{adultFare("standard", 99.0, false)},
 			Legs: []*Leg{&Leg{Dep: "123", Arr: "142", DepTime: time.Date(2019, time.Month(9), 8, 2, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 7, 30, 0, 0, time.UTC)},
 				&Leg{Dep: "142", Arr: "289", DepTime: time.Date(2019, time.Month(9), 8, 10, 5, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 9, 00, 25, 0, 0, time.UTC)},
 			},
 		},
 		&Trip{
-			Fares: []*Fare{&Fare{Price: 99.0, Type: "standard"}},
+			Fares: []*Fare{adultFare("standard", 99.0, false)},
 			Legs: []*Leg{&Leg{Dep: "123", Arr: "142", DepTime: time.Date(2019, time.Month(9), 8, 8, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 12, 15, 0, 0, time.UTC)},
 				&Leg{Dep: "142", Arr: "289", DepTime: time.Date(2019, time.Month(9), 8, 15, 30, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 9, 7, 25, 0, 0, time.UTC)},
 			},
 		},
 		&Trip{
-			Fares: []*Fare{&Fare{Price: 99.0, Type: "standard"}},
+			Fares: []*Fare{adultFare("standard", 99.0, false)},
 			Legs: []*Leg{&Leg{Dep: "123", Arr: "142", DepTime: time.Date(2019, time.Month(9), 8, 9, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 13, 40, 0, 0, time.UTC)},
 				&Leg{Dep: "142", Arr: "289", DepTime: time.Date(2019, time.Month(9), 8, 15, 30, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 9, 7, 25, 0, 0, time.UTC)},
 			},
 		},
 		&Trip{
-			Fares: []*Fare{&Fare{Price: 99.0, Type: "standard"}},
+			Fares: []*Fare{adultFare("standard", 99.0, false)},
 			Legs: []*Leg{&Leg{Dep: "123", Arr: "142", DepTime: time.Date(2019, time.Month(9), 8, 16, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 20, 15, 0, 0, time.UTC)},
 				&Leg{Dep: "142", Arr: "289", DepTime: time.Date(2019, time.Month(9), 8, 23, 20, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 9, 13, 45, 0, 0, time.UTC)},
 			},
 		},
 		&Trip{
-			Fares: []*Fare{&Fare{Price: 99.0, Type: "standard"}},
+			Fares: []*Fare{adultFare("standard", 99.0, false)},
 			Legs: []*Leg{&Leg{Dep: "123", Arr: "142", DepTime: time.Date(2019, time.Month(9), 8, 17, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 21, 15, 0, 0, time.UTC)},
 				&Leg{Dep: "142", Arr: "289", DepTime: time.Date(2019, time.Month(9), 8, 23, 20, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 9, 13, 45, 0, 0, time.UTC)},
 			},
 		},
 		&Trip{
-			Fares: []*Fare{&Fare{Price: 99.0, Type: "standard"}},
+			Fares: []*Fare{adultFare("standard", 99.0, false)},
 			Legs: []*Leg{&Leg{Dep: "123", Arr: "142", DepTime: time.Date(2019, time.Month(9), 8, 23, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 9, 4, 0, 0, 0, time.UTC)},
 				&Leg{Dep: "142", Arr: "289", DepTime: time.Date(2019, time.Month(9), 9, 6, 5, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 9, 21, 35, 0, 0, time.UTC)},
 			},
@@ -73,7 +77,58 @@ func TestGetTripsMegabus(t *testing.T) {
 			}
 			for j, ef := range want.Fares {
 				f := have.Fares[j]
-				if *f != *ef {
+				if !f.Equals(ef) {
+					t.Errorf("Fares slices differ. Want \n%v, \ngot \n%v", want.Fares, have.Fares)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTripsRangeMegabus(t *testing.T) {
+	sc := newMegabusScraper()
+	sc.client.Transport = newCassetteTransport(t, "megabus")
+	expectedTrips := []*Trip{
+		&Trip{
+			Fares: []*Fare{adultFare("standard", 45.0, false)},
+			Legs:  []*Leg{&Leg{Dep: "123", Arr: "289", DepTime: time.Date(2019, time.Month(9), 8, 6, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 8, 23, 10, 0, 0, time.UTC)}},
+		},
+		&Trip{
+			Fares: []*Fare{adultFare("standard", 89.0, false)},
+			Legs: []*Leg{&Leg{Dep: "123", Arr: "142", DepTime: time.Date(2019, time.Month(9), 9, 2, 0, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 9, 7, 30, 0, 0, time.UTC)},
+				&Leg{Dep: "142", Arr: "289", DepTime: time.Date(2019, time.Month(9), 9, 10, 5, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 10, 0, 25, 0, 0, time.UTC)},
+			},
+		},
+	}
+	trips, err := sc.GetTripsRange("123", "289", time.Date(2019, time.Month(9), 8, 0, 0, 0, 0, time.UTC), time.Date(2019, time.Month(9), 9, 0, 0, 0, 0, time.UTC), 1, 0, 0)
+	if err != nil {
+		t.Fatalf("Couldn't retrieve trips.\n%v", err)
+	}
+	checkTrips(t, expectedTrips, trips)
+}
+
+func checkTrips(t *testing.T, expectedTrips, trips []*Trip) {
+	if len(expectedTrips) != len(trips) {
+		t.Fatalf("Trip slices lengths differ. Want \n%v, \ngot %v", expectedTrips, trips)
+	}
+	for i, want := range expectedTrips {
+		have := trips[i]
+		t.Run(fmt.Sprintf("Trip %d", i), func(t *testing.T) {
+			if len(want.Legs) != len(have.Legs) {
+				t.Errorf("Legs slices differ. Want \n%v, \ngot \n%v", want.Legs, have.Legs)
+				return
+			}
+			if len(want.Fares) != len(have.Fares) {
+				t.Errorf("Fares slices differ. Want \n%v, \ngot \n%v", want.Fares, have.Fares)
+				return
+			}
+			for j, el := range want.Legs {
+				if !have.Legs[j].Equals(el) {
+					t.Errorf("Legs slices differ. Want \n%v, \ngot \n%v", want.Legs, have.Legs)
+				}
+			}
+			for j, ef := range want.Fares {
+				if !have.Fares[j].Equals(ef) {
 					t.Errorf("Fares slices differ. Want \n%v, \ngot \n%v", want.Fares, have.Fares)
 				}
 			}
@@ -81,26 +136,33 @@ func TestGetTripsMegabus(t *testing.T) {
 	}
 }
 
-func urlToFilePath() map[string]string {
-	return map[string]string{
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1413647":                                                                                                                                                                                                                    "./testScrapingSites/megabusItinerary0.json",
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1410032":                                                                                                                                                                                                                    "./testScrapingSites/megabusItinerary1.json",
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1407252":                                                                                                                                                                                                                    "./testScrapingSites/megabusItinerary2.json",
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1417628":                                                                                                                                                                                                                    "./testScrapingSites/megabusItinerary3.json",
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1419463":                                                                                                                                                                                                                    "./testScrapingSites/megabusItinerary4.json",
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1403592":                                                                                                                                                                                                                    "./testScrapingSites/megabusItinerary5.json",
-		"https://us.megabus.com/journey-planner/journeys?days=1&concessionCount=0&departureDate=2019-9-8&destinationId=289&inboundOtherDisabilityCount=0&inboundPcaCount=0&inboundWheelchairSeated=0&nusCount=0&originId=123&otherDisabilityCount=0&pcaCount=0&totalPassengers=1&wheelchairSeated=0": "./testScrapingSites/megabusTrips.html",
+func adultFare(fareType string, price float64, taxesIncluded bool) *Fare {
+	m := money.FromFloat(price, "USD")
+	return &Fare{Type: fareType, Price: m, Prices: map[PassengerType]money.Money{Adult: m}, Total: m, TaxesIncluded: taxesIncluded}
+}
+
+func BenchmarkGetTripsMegabus(b *testing.B) {
+	for _, workers := range []int{1, defaultMegabusWorkers} {
+		b.Run(fmt.Sprintf("%d workers", workers), func(b *testing.B) {
+			sc := newMegabusScraper()
+			sc.client.Transport = newLatencyRoundTripper(newCassetteTransport(b, "megabus"), time.Millisecond*20)
+			sc.limiter = rate.NewLimiter(rate.Inf, 0)
+			sc.workers = workers
+			for i := 0; i < b.N; i++ {
+				_, err := sc.GetTrips("123", "289", 8, 9, 2019, 1, 0, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
 	}
 }
 
-func urlToContentType() map[string]string {
-	return map[string]string{
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1413647":                                                                                                                                                                                                                    "application/json; charset=utf-8",
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1410032":                                                                                                                                                                                                                    "application/json; charset=utf-8",
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1407252":                                                                                                                                                                                                                    "application/json; charset=utf-8",
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1403592":                                                                                                                                                                                                                    "application/json; charset=utf-8",
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1417628":                                                                                                                                                                                                                    "application/json; charset=utf-8",
-		"https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1419463":                                                                                                                                                                                                                    "application/json; charset=utf-8",
-		"https://us.megabus.com/journey-planner/journeys?days=1&concessionCount=0&departureDate=2019-9-8&destinationId=289&inboundOtherDisabilityCount=0&inboundPcaCount=0&inboundWheelchairSeated=0&nusCount=0&originId=123&otherDisabilityCount=0&pcaCount=0&totalPassengers=1&wheelchairSeated=0": "text/html; charset=utf-8",
+// newCassetteTransport replays the interactions recorded in testScrapingSites/cassettes/<name>.json.
+func newCassetteTransport(tb testing.TB, name string) *replay.Transport {
+	rt, err := replay.New(fmt.Sprintf("./testScrapingSites/cassettes/%s.json", name), replay.Replay)
+	if err != nil {
+		tb.Fatal(err)
 	}
+	return rt
 }
@@ -0,0 +1,117 @@
+package scraping
+
+import (
+	"sort"
+	"time"
+)
+
+// Zones tells the time zone of a stop. Leg times are wall-clock times local to their stops, so durations need to know
+// where they are. Stops it doesn't know, or all of them if nil, are taken as UTC.
+type Zones func(stop string) *time.Location
+
+// ZonesFromNames creates Zones from the IANA time zone names of the stops, such as "America/New_York".
+func ZonesFromNames(names map[string]string) (Zones, error) {
+	locations := make(map[string]*time.Location)
+	for stop, name := range names {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, err
+		}
+		locations[stop] = loc
+	}
+	return func(stop string) *time.Location {
+		return locations[stop]
+	}, nil
+}
+
+// instant returns the actual instant of the wall-clock time t at stop.
+func (z Zones) instant(stop string, t time.Time) time.Time {
+	loc := time.UTC
+	if z != nil {
+		if l := z(stop); l != nil {
+			loc = l
+		}
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+func (l *Leg) Duration(z Zones) time.Duration {
+	return z.instant(l.Arr, l.ArrTime).Sub(z.instant(l.Dep, l.DepTime))
+}
+
+// Departure returns the wall-clock time the trip departs at, and Arrival the one it arrives at.
+func (t *Trip) Departure() time.Time {
+	if len(t.Legs) == 0 {
+		return time.Time{}
+	}
+	return t.Legs[0].DepTime
+}
+
+func (t *Trip) Arrival() time.Time {
+	if len(t.Legs) == 0 {
+		return time.Time{}
+	}
+	return t.Legs[len(t.Legs)-1].ArrTime
+}
+
+// Duration returns the time from the departure of the first leg to the arrival of the last one, layovers included.
+func (t *Trip) Duration(z Zones) time.Duration {
+	if len(t.Legs) == 0 {
+		return 0
+	}
+	first, last := t.Legs[0], t.Legs[len(t.Legs)-1]
+	return z.instant(last.Arr, last.ArrTime).Sub(z.instant(first.Dep, first.DepTime))
+}
+
+// Layovers returns the waits between every leg and the next one.
+func (t *Trip) Layovers(z Zones) []time.Duration {
+	layovers := make([]time.Duration, 0)
+	for i := 1; i < len(t.Legs); i++ {
+		prev, l := t.Legs[i-1], t.Legs[i]
+		layovers = append(layovers, z.instant(l.Dep, l.DepTime).Sub(z.instant(prev.Arr, prev.ArrTime)))
+	}
+	return layovers
+}
+
+func (t *Trip) Transfers() int {
+	if len(t.Legs) == 0 {
+		return 0
+	}
+	return len(t.Legs) - 1
+}
+
+// Cheapest returns the fare of the trip with the lowest total, or nil if it has none. Fares in a currency other than
+// the first one's aren't compared.
+func (t *Trip) Cheapest() *Fare {
+	var cheapest *Fare
+	for _, f := range t.Fares {
+		if cheapest == nil {
+			cheapest = f
+			continue
+		}
+		if cmp, err := f.Total.Cmp(cheapest.Total); err == nil && cmp < 0 {
+			cheapest = f
+		}
+	}
+	return cheapest
+}
+
+// Overnight tells whether the trip arrives on a later day than it departs, local to the stops.
+func (t *Trip) Overnight() bool {
+	dep, arr := t.Departure(), t.Arrival()
+	return time.Date(arr.Year(), arr.Month(), arr.Day(), 0, 0, 0, 0, time.UTC).After(time.Date(dep.Year(), dep.Month(), dep.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+// ProvidersOf returns the ids of the providers operating some of trips, sorted.
+func ProvidersOf(trips []*Trip) []int {
+	seen := make(map[int]bool)
+	ids := make([]int, 0)
+	for _, t := range trips {
+		if !seen[t.Provider] {
+			seen[t.Provider] = true
+			ids = append(ids, t.Provider)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
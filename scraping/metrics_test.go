@@ -0,0 +1,96 @@
+package scraping
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func metricsTestTrip(depHour int, fares ...*Fare) *Trip {
+	dep := time.Date(2019, time.Month(9), 8, depHour, 0, 0, 0, time.UTC)
+	return &Trip{
+		Legs: []*Leg{
+			newLeg("BOS", "FLL", "NK123", dep, dep.Add(time.Hour*3)),
+			newLeg("FLL", "DEN", "NK456", dep.Add(time.Hour*5), dep.Add(time.Hour*7)),
+		},
+		Fares: fares,
+	}
+}
+
+func TestTripMetrics(t *testing.T) {
+	zones, err := ZonesFromNames(map[string]string{"BOS": "America/New_York", "FLL": "America/New_York", "DEN": "America/Denver"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trip := metricsTestTrip(20, adultFare("standard", 120, true), adultFare("member", 90, true))
+
+	if d := trip.Duration(nil); d != time.Hour*7 {
+		t.Errorf("Expected 7h in UTC, got %v", d)
+	}
+	// Denver is 2 hours behind Boston.
+	if d := trip.Duration(zones); d != time.Hour*9 {
+		t.Errorf("Expected 9h, got %v", d)
+	}
+	if d := trip.Legs[1].Duration(zones); d != time.Hour*4 {
+		t.Errorf("Expected the second leg to last 4h, got %v", d)
+	}
+	if layovers := trip.Layovers(zones); !reflect.DeepEqual(layovers, []time.Duration{time.Hour * 2}) {
+		t.Errorf("Expected a 2h layover, got %v", layovers)
+	}
+	if n := trip.Transfers(); n != 1 {
+		t.Errorf("Expected a transfer, got %d", n)
+	}
+	if f := trip.Cheapest(); f.Type != "member" {
+		t.Errorf("Expected the member fare to be the cheapest, got %v", f)
+	}
+	if !trip.Overnight() || metricsTestTrip(7).Overnight() {
+		t.Errorf("Only the trip departing at 20:00 is overnight")
+	}
+	if (&Trip{}).Cheapest() != nil || (&Trip{}).Duration(zones) != 0 {
+		t.Errorf("Expected no metrics for an empty trip")
+	}
+
+	trips := []*Trip{{Provider: 3}, {Provider: 1}, {Provider: 3}}
+	if ids := ProvidersOf(trips); !reflect.DeepEqual(ids, []int{1, 3}) {
+		t.Errorf("Expected providers [1 3], got %v", ids)
+	}
+}
+
+func TestFilterAndSortTrips(t *testing.T) {
+	morning := metricsTestTrip(7, adultFare("standard", 150, true))
+	evening := metricsTestTrip(19, adultFare("standard", 100, true), adultFare("member", 80, true))
+	direct := &Trip{
+		Legs:  []*Leg{newLeg("BOS", "DEN", "NK789", morning.Departure().Add(time.Hour), morning.Departure().Add(time.Hour*5))},
+		Fares: []*Fare{adultFare("standard", 100, true)},
+	}
+	free := metricsTestTrip(23)
+	trips := []*Trip{morning, evening, direct, free}
+
+	tests := []struct {
+		name     string
+		filters  []Filter
+		expected []*Trip
+	}{
+		{"no filters", nil, trips},
+		{"direct", []Filter{MaxLayovers(0)}, []*Trip{direct}},
+		{"short", []Filter{MaxDuration(time.Hour*6, nil)}, []*Trip{direct}},
+		{"morning", []Filter{DepartingBetween(time.Hour*6, time.Hour*12)}, []*Trip{morning, direct}},
+		{"night", []Filter{DepartingBetween(time.Hour*18, time.Hour*2)}, []*Trip{evening, free}},
+		{"member", []Filter{WithFareType("member")}, []*Trip{evening}},
+		{"standard in the morning", []Filter{WithFareType("standard"), MaxLayovers(1), DepartingBetween(time.Hour*7, time.Hour*7)}, []*Trip{morning}},
+	}
+	for _, test := range tests {
+		if got := FilterTrips(trips, test.filters...); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+
+	SortTrips(trips, ByPrice, ByDuration(nil))
+	if expected := []*Trip{evening, direct, morning, free}; !reflect.DeepEqual(trips, expected) {
+		t.Errorf("Expected %v, got %v", expected, trips)
+	}
+	SortTrips(trips, ByDeparture)
+	if expected := []*Trip{morning, direct, evening, free}; !reflect.DeepEqual(trips, expected) {
+		t.Errorf("Expected %v, got %v", expected, trips)
+	}
+}
@@ -0,0 +1,134 @@
+package scraping
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jcasado94/connecc/providers"
+	"golang.org/x/time/rate"
+)
+
+// providerScraper drops the fares a scraper returns that aren't of the fare types its provider sells, so that fare
+// types unknown to the catalogue, such as a new bundle column, don't get ingested unnoticed.
+type providerScraper struct {
+	scraper  Scraper
+	provider *providers.Provider
+}
+
+// NewProviderScraper creates the scraper of provider p with the defaults set in the catalogue, pricing in its currency.
+// Providers whose sites only price in a currency of their own can't be set another one. The searches it makes drop,
+// and log, the fares of types the catalogue doesn't list.
+func NewProviderScraper(p *providers.Provider) (Scraper, error) {
+	var sc Scraper
+	switch p.Name {
+	case "spirit":
+		err := checkCurrency(p, spiritCurrency)
+		if err != nil {
+			return nil, err
+		}
+		sessions := defaultSpiritSessions
+		if p.Scraper.Workers > 0 {
+			sessions = p.Scraper.Workers
+		}
+		sc = NewSpiritScraperPool(sessions)
+	case "megabus":
+		mb := newMegabusScraper()
+		mb.currency = p.Currency
+		if p.Scraper.Workers > 0 {
+			mb.workers = p.Scraper.Workers
+		}
+		mb.limiter = providerLimiter(p, megabusRequestInterval, mb.workers)
+		sc = mb
+	case "flixbus":
+		fb := NewFlixbusScraper(p.Scraper.StopIds)
+		fb.config.Currency = p.Currency
+		fb.limiter = providerLimiter(p, flixbusRequestInterval, 1)
+		sc = fb
+	case "amtrak":
+		err := checkCurrency(p, amtrakCurrency)
+		if err != nil {
+			return nil, err
+		}
+		am := NewAmtrakScraper()
+		am.limiter = providerLimiter(p, amtrakRequestInterval, 1)
+		sc = am
+	case "frontier":
+		err := checkCurrency(p, frontierCurrency)
+		if err != nil {
+			return nil, err
+		}
+		fr := NewFrontierScraper()
+		fr.limiter = providerLimiter(p, frontierRequestInterval, 1)
+		sc = fr
+	default:
+		return nil, fmt.Errorf("No scraper for provider %s", p.Name)
+	}
+	return &providerScraper{
+		scraper:  sc,
+		provider: p,
+	}, nil
+}
+
+func checkCurrency(p *providers.Provider, currency string) error {
+	if p.Currency != currency {
+		return fmt.Errorf("Provider %s prices in %s, not %s", p.Name, currency, p.Currency)
+	}
+	return nil
+}
+
+func providerLimiter(p *providers.Provider, defaultInterval time.Duration, burst int) *rate.Limiter {
+	interval := defaultInterval
+	if p.Scraper.RequestInterval.Duration > 0 {
+		interval = p.Scraper.RequestInterval.Duration
+	}
+	return rate.NewLimiter(rate.Every(interval), burst)
+}
+
+func (sc *providerScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
+	trips, err := sc.scraper.GetTrips(departure, arrival, day, month, year, adults, children, infants)
+	if err != nil {
+		return []*Trip{}, err
+	}
+	return sc.checkFareTypes(trips)
+}
+
+func (sc *providerScraper) GetTripsRange(departure, arrival string, from, to time.Time, adults, children, infants int) ([]*Trip, error) {
+	trips, err := GetTripsRange(sc.scraper, departure, arrival, from, to, adults, children, infants)
+	if err != nil {
+		return []*Trip{}, err
+	}
+	return sc.checkFareTypes(trips)
+}
+
+// checkFareTypes drops the fares of the trips found of types the provider doesn't sell, stamping the trips with the
+// provider and the time. Trips left without fares are kept, and logged, for validation to reject them along with the
+// ones found with none.
+func (sc *providerScraper) checkFareTypes(trips []*Trip) ([]*Trip, error) {
+	now := time.Now()
+	unknown := make(map[string]bool)
+	emptied := 0
+	for _, t := range trips {
+		t.Provider, t.ScrapedAt = sc.provider.Id, now
+		fares := make([]*Fare, 0, len(t.Fares))
+		for _, f := range t.Fares {
+			err := sc.provider.CheckFareType(f.Type)
+			if err != nil {
+				if !unknown[f.Type] {
+					log.Printf("%v. Dropping its fares.", err)
+					unknown[f.Type] = true
+				}
+				continue
+			}
+			fares = append(fares, f)
+		}
+		if len(fares) == 0 && len(t.Fares) > 0 {
+			emptied++
+		}
+		t.Fares = fares
+	}
+	if emptied > 0 {
+		log.Printf("Provider %s. %d of %d trips found are left without fares.", sc.provider.Name, emptied, len(trips))
+	}
+	return trips, nil
+}
@@ -0,0 +1,220 @@
+// Package replay provides an http.RoundTripper that records real HTTP interactions into a cassette file and replays
+// them later, so scrapers can be tested offline against real responses. It can be set as the Transport of an
+// http.Client or passed to a surf browser through SetTransport.
+//
+// To refresh a cassette, run the scraper once over a Transport created in Record mode and call Save.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Mode int
+
+const (
+	// Replay serves the interactions stored in the cassette and fails on any other request.
+	Replay Mode = iota
+	// Record performs the requests and stores every interaction into the cassette.
+	Record
+)
+
+// Cassette is the list of interactions stored in a cassette file.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+	// BodyFile, relative to the cassette file, holds the body instead of Body. It lets cassettes share big fixtures.
+	BodyFile string `json:"bodyFile,omitempty"`
+}
+
+// Transport is an http.RoundTripper backed by a cassette file.
+type Transport struct {
+	mode     Mode
+	path     string
+	cassette Cassette
+	// Transport performs the requests in Record mode. http.DefaultTransport is used if nil.
+	Transport http.RoundTripper
+
+	mu     sync.Mutex
+	served map[string]int
+}
+
+// New creates a Transport over the cassette at path. In Replay mode the cassette is loaded right away; in Record
+// mode it's written on Save.
+func New(path string, mode Mode) (*Transport, error) {
+	t := &Transport{
+		mode:   mode,
+		path:   path,
+		served: make(map[string]int),
+	}
+	if mode == Record {
+		return t, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &t.cassette)
+	if err != nil {
+		return nil, fmt.Errorf("replay: malformed cassette %s: %v", path, err)
+	}
+	return t, nil
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.mode == Record {
+		return t.record(r)
+	}
+	return t.replay(r)
+}
+
+// Save writes the recorded interactions into the cassette file.
+func (t *Transport) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(t.path, data, 0644)
+}
+
+func (t *Transport) record(r *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if r.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	rt := t.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	resp, err := rt.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.cassette.Interactions = append(t.cassette.Interactions, &Interaction{
+		Request: Request{
+			Method: r.Method,
+			URL:    r.URL.String(),
+			Body:   string(reqBody),
+		},
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: resp.Header,
+			Body:    string(respBody),
+		},
+	})
+	t.mu.Unlock()
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// replay serves the interactions matching the request in the order they were recorded, repeating the last one once
+// they run out.
+func (t *Transport) replay(r *http.Request) (*http.Response, error) {
+	key := matchKey(r.Method, r.URL)
+	t.mu.Lock()
+	matches := make([]*Interaction, 0)
+	for _, in := range t.cassette.Interactions {
+		u, err := url.Parse(in.Request.URL)
+		if err != nil {
+			t.mu.Unlock()
+			return nil, fmt.Errorf("replay: malformed URL %q in cassette %s: %v", in.Request.URL, t.path, err)
+		}
+		if matchKey(in.Request.Method, u) == key {
+			matches = append(matches, in)
+		}
+	}
+	if len(matches) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("replay: no interaction recorded in %s for %s", t.path, key)
+	}
+	i := t.served[key]
+	if i >= len(matches) {
+		i = len(matches) - 1
+	}
+	t.served[key]++
+	t.mu.Unlock()
+
+	in := matches[i]
+	body := []byte(in.Response.Body)
+	if in.Response.BodyFile != "" {
+		var err error
+		body, err = ioutil.ReadFile(filepath.Join(filepath.Dir(t.path), in.Response.BodyFile))
+		if err != nil {
+			return nil, err
+		}
+	}
+	header := make(http.Header)
+	for k, v := range in.Response.Headers {
+		header[k] = v
+	}
+	status := in.Response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}, nil
+}
+
+// matchKey identifies a request by its method, host, path and query, regardless of the order of the query parameters.
+func matchKey(method string, u *url.URL) string {
+	query := u.Query()
+	for _, values := range query {
+		sort.Strings(values)
+	}
+	key := fmt.Sprintf("%s %s%s", strings.ToUpper(method), u.Host, u.Path)
+	if len(query) > 0 {
+		key += "?" + query.Encode()
+	}
+	return key
+}
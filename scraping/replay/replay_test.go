@@ -0,0 +1,95 @@
+package replay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, `{"journeyId":"%s"}`, r.URL.Query().Get("journeyId"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+
+	recorder, err := New(path, Record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := http.Client{Transport: recorder}
+	for _, id := range []string{"1", "2"} {
+		resp, err := client.Get(fmt.Sprintf("%s/itinerary?journeyId=%s&days=1", server.URL, id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	err = recorder.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	player, err := New(path, Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = http.Client{Transport: player}
+
+	t.Run("Matches regardless of query order", func(t *testing.T) {
+		resp, err := client.Get(fmt.Sprintf("%s/itinerary?days=1&journeyId=2", server.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		if string(body) != `{"journeyId":"2"}` {
+			t.Errorf("Expected the body of journey 2, got %s", body)
+		}
+		if resp.StatusCode != http.StatusAccepted {
+			t.Errorf("Expected status %d, got %d", http.StatusAccepted, resp.StatusCode)
+		}
+		if resp.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("Headers weren't replayed: %v", resp.Header)
+		}
+	})
+
+	t.Run("Fails on unmatched requests", func(t *testing.T) {
+		_, err := client.Get(fmt.Sprintf("%s/itinerary?days=1&journeyId=3", server.URL))
+		if err == nil || !strings.Contains(err.Error(), "no interaction recorded") {
+			t.Errorf("Expected an unmatched request error, got %v", err)
+		}
+	})
+}
+
+func TestReplayBodyFile(t *testing.T) {
+	player, err := New("../testScrapingSites/cassettes/megabus.json", Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://us.megabus.com/journey-planner/api/itinerary?journeyId=*1413647", nil)
+	resp, err := player.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	expected, _ := ioutil.ReadFile("../testScrapingSites/megabusItinerary0.json")
+	if string(body) != string(expected) {
+		t.Error("Body wasn't read from the body file")
+	}
+}
@@ -1,5 +1,48 @@
 package scraping
 
+import (
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+)
+
 type Scraper interface {
-	GetTrips(year, month, day, passengers int) ([]*Trip, error)
+	GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error)
+}
+
+// RangeScraper is implemented by scrapers that can retrieve several consecutive days in fewer requests than one search per day.
+type RangeScraper interface {
+	Scraper
+	GetTripsRange(departure, arrival string, from, to time.Time, adults, children, infants int) ([]*Trip, error)
+}
+
+// LowFare is the lowest price per adult shown for a day by the fare calendar of a provider. Days with no flights have
+// Available set to false and a zero Price.
+type LowFare struct {
+	Date      time.Time
+	Price     money.Money
+	Available bool
+}
+
+// LowFareScraper is implemented by scrapers whose providers show a fare calendar, giving the lowest fare of several
+// days without searching each of them.
+type LowFareScraper interface {
+	GetLowFares(departure, arrival string, from, to time.Time, adults, children, infants int) ([]LowFare, error)
+}
+
+// GetTripsRange retrieves the trips departing on every day between from and to, both included.
+// Scrapers that don't implement RangeScraper are queried once per day.
+func GetTripsRange(sc Scraper, departure, arrival string, from, to time.Time, adults, children, infants int) ([]*Trip, error) {
+	if rsc, ok := sc.(RangeScraper); ok {
+		return rsc.GetTripsRange(departure, arrival, from, to, adults, children, infants)
+	}
+	trips := make([]*Trip, 0)
+	for _, date := range daysBetween(from, to) {
+		dayTrips, err := sc.GetTrips(departure, arrival, date.Day(), int(date.Month()), date.Year(), adults, children, infants)
+		if err != nil {
+			return []*Trip{}, err
+		}
+		trips = append(trips, dayTrips...)
+	}
+	return trips, nil
 }
@@ -2,126 +2,397 @@ package scraping
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/headzoo/surf"
 	"github.com/headzoo/surf/browser"
+	"github.com/jcasado94/connecc/money"
 )
 
+const (
+	spiritOneWay            = "oneWay"
+	spiritRoundTrip         = "roundTrip"
+	spiritMultiCity         = "multiCity"
+	spiritMaxSegments       = 4
+	spiritDateLayout        = "1/2/2006"
+	spiritDateDisplayLayout = "01/02/2006"
+	spiritCurrency          = "USD"
+	defaultSpiritSessions   = 4
+	// spiritSessionAge is how long sessions are used before being renewed, shorter than the 20 minutes spirit.com
+	// keeps idle sessions for.
+	spiritSessionAge = time.Minute * 15
+	// spiritCalendarDays is the number of days shown by the week view of the fare calendar.
+	spiritCalendarDays = 7
+)
+
+var spiritItineraries = &itineraryExtractor{
+	rowsSelector:        ".rowsMarket%d",
+	fareColumnsSelector: ".typeth .sortTrigger",
+	fareTypes: map[string]string{
+		"9FC":      "9Dollar",
+		"Standard": "standard",
+	},
+	defaultFareColumns: []fareColumn{
+		{"9Dollar", ".memberFare.radio label"},
+		{"standard", ".standardFare.radio label"},
+	},
+	flightsSelector:       ".flight-info-body",
+	flightNumbersSelector: ".popUpContent .fi-header-text.text-uppercase.text-right",
+	timesSelector:         ".fi-text-bold",
+	depTimeIndex:          1,
+	arrTimeIndex:          3,
+	stationsSelector:      ".fi-text",
+	carrierPrefix:         "NK",
+	timeLayout:            "3:04 PM",
+	currency:              spiritCurrency,
+	fare:                  airlineFare,
+}
+
+// Segment is a single origin, destination and departure date of a search.
+type Segment struct {
+	Departure, Arrival string
+	Date               time.Time
+}
+
 type SpiritScraper struct {
-	browser *browser.Browser
+	// tokens holds a token per session in use, up to its capacity. Sessions get opened as searches need them, and idle
+	// holds the ones not in use.
+	tokens    chan struct{}
+	mu        sync.Mutex
+	idle      []*spiritSession
+	transport http.RoundTripper
+	now       func() time.Time
 }
 
+// spiritSession is a browser holding a session on spirit.com. Searches are made through the session's state, so a
+// session only serves a search at a time.
+type spiritSession struct {
+	browser  *browser.Browser
+	openedAt time.Time
+}
+
+// NewSpiritScraper creates a SpiritScraper able to make defaultSpiritSessions searches at once.
 func NewSpiritScraper() *SpiritScraper {
-	browser := surf.NewBrowser()
-	browser.SetUserAgent("Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/64.0.3282.186 Safari/537.36")
-	browser.Open("https://www.spirit.com/Default.aspx")
+	return NewSpiritScraperPool(defaultSpiritSessions)
+}
+
+// NewSpiritScraperPool creates a SpiritScraper able to make up to sessions searches at once. Sessions are opened the
+// first time they're needed and renewed once spiritSessionAge old or when spirit.com finds them expired.
+func NewSpiritScraperPool(sessions int) *SpiritScraper {
+	if sessions < 1 {
+		sessions = 1
+	}
 	return &SpiritScraper{
-		browser: browser,
+		tokens: make(chan struct{}, sessions),
+		now:    time.Now,
 	}
 }
 
-func (sc *SpiritScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
-	trips := make([]*Trip, 0)
-	var err error
-
-	sc.browser.Post("https://www.spirit.com/Default.aspx?action=search", "application/x-www-form-urlencoded",
-		strings.NewReader(fmt.Sprintf("bypassHC=False&birthdates=&lapoption=&awardFSNumber=&bookingType=F&hotelOnlyInput=&autoCompleteValueHidden=&carPickUpTime=16&carDropOffTime=16&tripType=oneWay&vacationPackageType=on&from=%s&to=%s&departDate=%d%%2F%d%%2F%d&departDateDisplay=08%%2F31%%2F2019&returnDate=09%%2F03%%2F2019&returnDateDisplay=09%%2F03%%2F2019&ADT=%d&CHD=%d&INF=%d&promoCode=&fromMultiCity1=&toMultiCity1=&dateMultiCity1=&dateMultiCityDisplay1=&fromMultiCity2=&toMultiCity2=&dateMultiCity2=&dateMultiCityDisplay2=&fromMultiCity3=&toMultiCity3=&dateMultiCity3=&dateMultiCityDisplay3=&fromMultiCity4=&toMultiCity4=&dateMultiCity4=&dateMultiCityDisplay4=&redeemMiles=false",
-			departure, arrival,
-			month, day, year,
-			adults, children, infants)))
-
-	sc.browser.Open("https://www.spirit.com/DPPCalendarMarket.aspx")
+// acquire waits until fewer sessions than the pool size are in use, then returns an idle session, or a new one if
+// there's none. Sessions too old to be trusted are renewed.
+func (sc *SpiritScraper) acquire() (*spiritSession, error) {
+	sc.tokens <- struct{}{}
+	sc.mu.Lock()
+	var s *spiritSession
+	if n := len(sc.idle); n > 0 {
+		s, sc.idle = sc.idle[n-1], sc.idle[:n-1]
+	}
+	sc.mu.Unlock()
 
-	sc.browser.Dom().Find(".rowsMarket1").Each(func(_ int, s *goquery.Selection) {
-		trip := Trip{}
+	if s == nil || sc.now().Sub(s.openedAt) > spiritSessionAge {
+		if s == nil {
+			s = &spiritSession{}
+		}
+		err := sc.open(s)
 		if err != nil {
-			return
+			// The session is dropped, and its token returned, so that a waiting search opens a new one.
+			<-sc.tokens
+			return nil, err
 		}
+	}
+	return s, nil
+}
 
-		trip.Fares = sc.getFares(s)
-		trip.Legs = sc.getLegs(s, year, month, day)
+func (sc *SpiritScraper) release(s *spiritSession) {
+	sc.mu.Lock()
+	sc.idle = append(sc.idle, s)
+	sc.mu.Unlock()
+	<-sc.tokens
+}
 
-		trips = append(trips, &trip)
-	})
+func (sc *SpiritScraper) open(s *spiritSession) error {
+	b := surf.NewBrowser()
+	b.SetUserAgent("Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/64.0.3282.186 Safari/537.36")
+	if sc.transport != nil {
+		b.SetTransport(sc.transport)
+	}
+	err := b.Open("https://www.spirit.com/Default.aspx")
+	if err != nil {
+		return fmt.Errorf("Spirit. Couldn't open a session: %v", err)
+	}
+	s.browser, s.openedAt = b, sc.now()
+	return nil
+}
 
+func (sc *SpiritScraper) GetTrips(departure, arrival string, day, month, year, adults, children, infants int) ([]*Trip, error) {
+	s, err := sc.acquire()
+	if err != nil {
+		return []*Trip{}, err
+	}
+	defer sc.release(s)
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	err = sc.search(s, spiritOneWay, []Segment{{departure, arrival, date}}, adults, children, infants)
 	if err != nil {
 		return []*Trip{}, err
 	}
+	return s.getTrips(1, date, newPassengers(adults, children, infants)), nil
+}
 
+// GetRoundTrips searches a round trip, returning the outbound and the inbound options separately.
+// CombineTrips pairs an outbound with an inbound option into a single trip with the combined fares.
+func (sc *SpiritScraper) GetRoundTrips(departure, arrival string, depDate, retDate time.Time, adults, children, infants int) (outbound, inbound []*Trip, err error) {
+	s, err := sc.acquire()
+	if err != nil {
+		return []*Trip{}, []*Trip{}, err
+	}
+	defer sc.release(s)
+	segments := []Segment{{departure, arrival, depDate}, {arrival, departure, retDate}}
+	passengers := newPassengers(adults, children, infants)
+	err = sc.search(s, spiritRoundTrip, segments, adults, children, infants)
+	if err != nil {
+		return []*Trip{}, []*Trip{}, err
+	}
+	return s.getTrips(1, depDate, passengers), s.getTrips(2, retDate, passengers), nil
+}
+
+// GetMultiCityTrips searches up to spiritMaxSegments segments at once, returning the options of segments[i] in the i-th slice.
+func (sc *SpiritScraper) GetMultiCityTrips(segments []Segment, adults, children, infants int) ([][]*Trip, error) {
+	if len(segments) == 0 || len(segments) > spiritMaxSegments {
+		return [][]*Trip{}, fmt.Errorf("Spirit. Multi-city searches need between 1 and %d segments, got %d", spiritMaxSegments, len(segments))
+	}
+	s, err := sc.acquire()
+	if err != nil {
+		return [][]*Trip{}, err
+	}
+	defer sc.release(s)
+	err = sc.search(s, spiritMultiCity, segments, adults, children, infants)
+	if err != nil {
+		return [][]*Trip{}, err
+	}
+	trips := make([][]*Trip, len(segments))
+	passengers := newPassengers(adults, children, infants)
+	for i, segment := range segments {
+		trips[i] = s.getTrips(i+1, segment.Date, passengers)
+	}
 	return trips, nil
 }
 
-func (sc *SpiritScraper) getFares(s *goquery.Selection) []*Fare {
-	var fares []*Fare
-	nineDollarFareSlice := strings.Split(s.Find(".memberItem.radio label").Text(), "$")
-	if len(nineDollarFareSlice) > 1 {
-		price, err := strconv.ParseFloat(nineDollarFareSlice[1], 64)
-		if err != nil {
-			return nil
+// GetTripsRange searches the days between from and to, both included. The fare calendar is read for the whole range
+// first, with a search per week, and full itineraries are then only searched for the days the calendar shows flights
+// on and that weren't searched for it.
+func (sc *SpiritScraper) GetTripsRange(departure, arrival string, from, to time.Time, adults, children, infants int) ([]*Trip, error) {
+	s, err := sc.acquire()
+	if err != nil {
+		return []*Trip{}, err
+	}
+	defer sc.release(s)
+	calendar, searched, err := sc.searchCalendar(s, departure, arrival, from, to, adults, children, infants)
+	if err != nil {
+		return []*Trip{}, err
+	}
+	trips := make([]*Trip, 0)
+	for _, date := range daysBetween(from, to) {
+		dayTrips, ok := searched[date]
+		if !ok {
+			if fare, known := calendar[date]; known && !fare.Available {
+				continue
+			}
+			err := sc.search(s, spiritOneWay, []Segment{{departure, arrival, date}}, adults, children, infants)
+			if err != nil {
+				return []*Trip{}, err
+			}
+			dayTrips = s.getTrips(1, date, newPassengers(adults, children, infants))
 		}
-		fares = append(fares, newFare("9Dollar", price))
+		trips = append(trips, dayTrips...)
 	}
+	return trips, nil
+}
 
-	standardFareSlice := strings.Split(s.Find(".standardFare.radio label").Text(), "$")
-	if len(standardFareSlice) > 1 {
-		price, err := strconv.ParseFloat(standardFareSlice[1], 64)
-		if err != nil {
-			return nil
+// GetLowFares reads the lowest fare of every day between from and to, both included, from the fare calendar. A search
+// is made per week of the range. Days the calendar didn't show are left out.
+func (sc *SpiritScraper) GetLowFares(departure, arrival string, from, to time.Time, adults, children, infants int) ([]LowFare, error) {
+	s, err := sc.acquire()
+	if err != nil {
+		return []LowFare{}, err
+	}
+	defer sc.release(s)
+	calendar, _, err := sc.searchCalendar(s, departure, arrival, from, to, adults, children, infants)
+	if err != nil {
+		return []LowFare{}, err
+	}
+	fares := make([]LowFare, 0)
+	for _, date := range daysBetween(from, to) {
+		if fare, ok := calendar[date]; ok {
+			fares = append(fares, fare)
 		}
-		fares = append(fares, newFare("standard", price))
 	}
-
-	return fares
+	return fares, nil
 }
 
-func (sc *SpiritScraper) getLegs(s *goquery.Selection, year, month, day int) []*Leg {
-	var legs []*Leg
-	sFlightNumbers := s.Find(".popUpContent .fi-header-text.text-uppercase.text-right")
-	var arrTime, depTime time.Time
-	s.Find(".flight-info-body").Each(func(i int, s *goquery.Selection) {
-		fieldsDates := s.Find(".fi-text-bold")
-		depHour, depMin, err := processTime(fieldsDates.Get(1).FirstChild.Data)
-		if err != nil {
-			return
+// searchCalendar searches enough days between from and to for the week views of the fare calendar to cover the range,
+// returning the calendar read and the trips of the days searched. The week view is centered on the day searched.
+func (sc *SpiritScraper) searchCalendar(s *spiritSession, departure, arrival string, from, to time.Time, adults, children, infants int) (map[time.Time]LowFare, map[time.Time][]*Trip, error) {
+	calendar := make(map[time.Time]LowFare)
+	searched := make(map[time.Time][]*Trip)
+	days := daysBetween(from, to)
+	for i, day := range days {
+		if _, ok := calendar[day]; ok {
+			continue
 		}
-		if arrTime.IsZero() {
-			depTime = time.Date(year, time.Month(month), day, depHour, depMin, 0, 0, time.UTC)
-		} else {
-			depTime = processDayDifference(&arrTime, depHour, depMin)
+		next := i + spiritCalendarDays/2
+		if next >= len(days) {
+			next = len(days) - 1
 		}
-		arrHour, arrMin, err := processTime(fieldsDates.Get(3).FirstChild.Data)
+		date := days[next]
+		if _, ok := searched[date]; ok {
+			continue
+		}
+		err := sc.search(s, spiritOneWay, []Segment{{departure, arrival, date}}, adults, children, infants)
 		if err != nil {
-			return
+			return nil, nil, err
+		}
+		searched[date] = s.getTrips(1, date, newPassengers(adults, children, infants))
+		for d, fare := range s.getCalendar(date) {
+			calendar[d] = fare
 		}
-		arrTime = processDayDifference(&depTime, arrHour, arrMin)
+	}
+	return calendar, searched, nil
+}
 
-		fieldsLocations := s.Find(".fi-text")
-		dep := strings.TrimSpace(fieldsLocations.Get(0).FirstChild.Data)
-		arr := strings.TrimSpace(fieldsLocations.Get(1).FirstChild.Data)
+// search makes a search through session s. spirit.com sends expired sessions back to its home page instead of the
+// results, in which case the session is renewed and the search made again.
+func (sc *SpiritScraper) search(s *spiritSession, tripType string, segments []Segment, adults, children, infants int) error {
+	err := s.search(tripType, segments, adults, children, infants)
+	if err != nil || s.showsResults() {
+		return err
+	}
+	err = sc.open(s)
+	if err != nil {
+		return err
+	}
+	err = s.search(tripType, segments, adults, children, infants)
+	if err != nil {
+		return err
+	}
+	if !s.showsResults() {
+		return fmt.Errorf("Spirit. Search redirected to %v", s.browser.Url())
+	}
+	return nil
+}
 
-		flightNumberSlice := strings.Split(sFlightNumbers.Get(i).FirstChild.Data, " ")
-		flightNumber := fmt.Sprintf("NK%s", flightNumberSlice[len(flightNumberSlice)-1])
+func (s *spiritSession) search(tripType string, segments []Segment, adults, children, infants int) error {
+	err := s.browser.Post("https://www.spirit.com/Default.aspx?action=search", "application/x-www-form-urlencoded",
+		strings.NewReader(spiritSearchForm(tripType, segments, adults, children, infants).Encode()))
+	if err != nil {
+		return err
+	}
 
-		legs = append(legs, newLeg(dep, arr, flightNumber, depTime, arrTime))
-	})
+	return s.browser.Open("https://www.spirit.com/DPPCalendarMarket.aspx")
+}
 
-	return legs
+func (s *spiritSession) showsResults() bool {
+	u := s.browser.Url()
+	return u != nil && strings.HasSuffix(u.Path, "/DPPCalendarMarket.aspx")
 }
 
-func processTime(time string) (depHour, depMin int, err error) {
-	timeSlice := strings.Split(time, " ")
-	depTimeSlice := strings.Split(timeSlice[0], ":")
-	depHour, err = strconv.Atoi(depTimeSlice[0])
-	depMin, err = strconv.Atoi(depTimeSlice[1])
-	if err != nil {
-		return 0, 0, err
+func spiritSearchForm(tripType string, segments []Segment, adults, children, infants int) url.Values {
+	form := url.Values{
+		"bypassHC":                {"False"},
+		"birthdates":              {""},
+		"lapoption":               {""},
+		"awardFSNumber":           {""},
+		"bookingType":             {"F"},
+		"hotelOnlyInput":          {""},
+		"autoCompleteValueHidden": {""},
+		"carPickUpTime":           {"16"},
+		"carDropOffTime":          {"16"},
+		"tripType":                {tripType},
+		"vacationPackageType":     {"on"},
+		"ADT":                     {strconv.Itoa(adults)},
+		"CHD":                     {strconv.Itoa(children)},
+		"INF":                     {strconv.Itoa(infants)},
+		"promoCode":               {""},
+		"redeemMiles":             {"false"},
 	}
-	if timeSlice[1] == "PM" {
-		depHour += 12
+
+	first := segments[0]
+	form.Set("from", first.Departure)
+	form.Set("to", first.Arrival)
+	form.Set("departDate", first.Date.Format(spiritDateLayout))
+	form.Set("departDateDisplay", first.Date.Format(spiritDateDisplayLayout))
+	returnDate := first.Date
+	if tripType == spiritRoundTrip {
+		returnDate = segments[1].Date
 	}
-	return depHour, depMin, nil
+	form.Set("returnDate", returnDate.Format(spiritDateLayout))
+	form.Set("returnDateDisplay", returnDate.Format(spiritDateDisplayLayout))
+
+	for i := 1; i <= spiritMaxSegments; i++ {
+		var from, to, date, dateDisplay string
+		if tripType == spiritMultiCity && i <= len(segments) {
+			segment := segments[i-1]
+			from, to = segment.Departure, segment.Arrival
+			date, dateDisplay = segment.Date.Format(spiritDateLayout), segment.Date.Format(spiritDateDisplayLayout)
+		}
+		form.Set(fmt.Sprintf("fromMultiCity%d", i), from)
+		form.Set(fmt.Sprintf("toMultiCity%d", i), to)
+		form.Set(fmt.Sprintf("dateMultiCity%d", i), date)
+		form.Set(fmt.Sprintf("dateMultiCityDisplay%d", i), dateDisplay)
+	}
+
+	return form
+}
+
+// getTrips parses the options listed for the market-th segment of the search, departing on date.
+func (s *spiritSession) getTrips(market int, date time.Time, passengers Passengers) []*Trip {
+	return spiritItineraries.trips(s.browser.Dom(), market, date, passengers)
+}
+
+// getCalendar reads the week view of the fare calendar shown for a search on date, with the lowest fare of each day.
+// Days whose fare can't be read are left out.
+func (s *spiritSession) getCalendar(date time.Time) map[time.Time]LowFare {
+	calendar := make(map[time.Time]LowFare)
+	r := regexp.MustCompile(`^contentCell_(\d+)_(\d+)_1$`)
+	s.browser.Dom().Find("#calendarMarket1 .changeFlightDate").Each(func(_ int, cell *goquery.Selection) {
+		id, _ := cell.Attr("id")
+		match := r.FindStringSubmatch(id)
+		if match == nil {
+			return
+		}
+		day, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		year := date.Year()
+		if month-int(date.Month()) > 6 {
+			year--
+		} else if int(date.Month())-month > 6 {
+			year++
+		}
+		fare := LowFare{Date: time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)}
+		if !cell.HasClass("not_available") {
+			price, err := money.Parse(cell.Find(".fare_price").Text(), spiritCurrency)
+			if err != nil {
+				return
+			}
+			fare.Price, fare.Available = price, true
+		}
+		calendar[fare.Date] = fare
+	})
+	return calendar
 }
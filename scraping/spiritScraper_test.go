@@ -2,13 +2,19 @@ package scraping
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/jcasado94/connecc/money"
 )
 
 func TestGetTripsSpirit(t *testing.T) {
 	sc := NewSpiritScraper()
-	sc.browser.SetTransport(newSingularMockRoundTripper("./testScrapingSites/spiritAirlines.html", "text/html; charset=utf-8"))
+	sc.transport = newCassetteTransport(t, "spirit")
 	trips, err := sc.GetTrips("BOS", "DEN", 13, 9, 2019, 1, 0, 0)
 	if err != nil {
 		t.Error("Error while getting the trips")
@@ -17,7 +23,7 @@ func TestGetTripsSpirit(t *testing.T) {
 
 	expectedTrips := []Trip{
 		Trip{
-			Fares: []*Fare{&Fare{Price: 158.18, Type: "standard"}},
+			Fares: []*Fare{adultFare("standard", 158.18, true)},
 			Legs: []*Leg{
 				&Leg{Dep: "Boston, MA", Arr: "Baltimore, MD / Washington, DC AREA", DepTime: time.Date(2019, time.Month(9), 13, 7, 45, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 9, 24, 0, 0, time.UTC), Id: "NK2025"},
 				&Leg{Dep: "Baltimore, MD / Washington, DC AREA", Arr: "Minneapolis/St. Paul, MN", DepTime: time.Date(2019, time.Month(9), 13, 11, 55, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 13, 32, 0, 0, time.UTC), Id: "NK381"},
@@ -25,14 +31,14 @@ func TestGetTripsSpirit(t *testing.T) {
 			},
 		},
 		Trip{
-			Fares: []*Fare{&Fare{Price: 153.98, Type: "standard"}},
+			Fares: []*Fare{adultFare("standard", 153.98, true)},
 			Legs: []*Leg{
 				&Leg{Dep: "Boston, MA", Arr: "Baltimore, MD / Washington, DC AREA", DepTime: time.Date(2019, time.Month(9), 13, 7, 45, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 9, 24, 0, 0, time.UTC), Id: "NK2025"},
 				&Leg{Dep: "Baltimore, MD / Washington, DC AREA", Arr: "Denver, CO", DepTime: time.Date(2019, time.Month(9), 13, 20, 19, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 22, 17, 0, 0, time.UTC), Id: "NK115"},
 			},
 		},
 		Trip{
-			Fares: []*Fare{&Fare{Price: 122.08, Type: "9Dollar"}, &Fare{Price: 171.98, Type: "standard"}},
+			Fares: []*Fare{adultFare("9Dollar", 122.08, true), adultFare("standard", 171.98, true)},
 			Legs: []*Leg{
 				&Leg{Dep: "Boston, MA", Arr: "Fort Lauderdale, FL / Miami, FL AREA", DepTime: time.Date(2019, time.Month(9), 13, 15, 35, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 19, 04, 0, 0, time.UTC), Id: "NK1611"},
 				&Leg{Dep: "Fort Lauderdale, FL / Miami, FL AREA", Arr: "Denver, CO", DepTime: time.Date(2019, time.Month(9), 13, 21, 45, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 13, 23, 59, 0, 0, time.UTC), Id: "NK355"},
@@ -61,10 +67,314 @@ func TestGetTripsSpirit(t *testing.T) {
 			}
 			for j, ef := range want.Fares {
 				f := have.Fares[j]
-				if *f != *ef {
+				if !f.Equals(ef) {
 					t.Errorf("Fares slices differ. Want \n%v, \ngot \n%v", want.Fares, have.Fares)
 				}
 			}
 		})
 	}
 }
+
+func TestGetTripsRangeSpirit(t *testing.T) {
+	sc := NewSpiritScraper()
+	rt := &spiritSessionRoundTripper{page: newSingularMockRoundTripper("./testScrapingSites/spiritAirlines.html", "text/html; charset=utf-8")}
+	sc.transport = rt
+	// The search for the calendar is made on the 11th, whose week view shows no flights on the 10th and leaves the 9th
+	// out, so only the 9th is searched besides.
+	trips, err := sc.GetTripsRange("BOS", "DEN", time.Date(2019, time.Month(9), 9, 0, 0, 0, 0, time.UTC), time.Date(2019, time.Month(9), 11, 0, 0, 0, 0, time.UTC), 1, 0, 0)
+	if err != nil {
+		t.Fatalf("Error while getting the trips: %v", err)
+	}
+	expectedDays := []int{9, 9, 9, 11, 11, 11}
+	if len(expectedDays) != len(trips) {
+		t.Fatalf("Trip slices lengths differ. Want %d trips, got %v", len(expectedDays), trips)
+	}
+	for i, day := range expectedDays {
+		if trips[i].Legs[0].DepTime.Day() != day {
+			t.Errorf("Trip %d departs on the wrong day. Want %d, got %v", i, day, trips[i].Legs[0].DepTime)
+		}
+	}
+	if rt.searches != 2 {
+		t.Errorf("Expected 2 searches, got %d", rt.searches)
+	}
+}
+
+func TestGetLowFaresSpirit(t *testing.T) {
+	sc := NewSpiritScraper()
+	rt := &spiritSessionRoundTripper{page: newSingularMockRoundTripper("./testScrapingSites/spiritAirlines.html", "text/html; charset=utf-8")}
+	sc.transport = rt
+	fares, err := sc.GetLowFares("BOS", "DEN", time.Date(2019, time.Month(9), 10, 0, 0, 0, 0, time.UTC), time.Date(2019, time.Month(9), 16, 0, 0, 0, 0, time.UTC), 1, 0, 0)
+	if err != nil {
+		t.Fatalf("Error while getting the fares: %v", err)
+	}
+	expected := []LowFare{
+		{Date: time.Date(2019, time.Month(9), 10, 0, 0, 0, 0, time.UTC)},
+		{Date: time.Date(2019, time.Month(9), 11, 0, 0, 0, 0, time.UTC), Price: money.New(10999, "USD"), Available: true},
+		{Date: time.Date(2019, time.Month(9), 12, 0, 0, 0, 0, time.UTC), Price: money.New(10318, "USD"), Available: true},
+		{Date: time.Date(2019, time.Month(9), 13, 0, 0, 0, 0, time.UTC), Price: money.New(12208, "USD"), Available: true},
+		{Date: time.Date(2019, time.Month(9), 14, 0, 0, 0, 0, time.UTC), Price: money.New(10700, "USD"), Available: true},
+		{Date: time.Date(2019, time.Month(9), 15, 0, 0, 0, 0, time.UTC), Price: money.New(11698, "USD"), Available: true},
+		{Date: time.Date(2019, time.Month(9), 16, 0, 0, 0, 0, time.UTC), Price: money.New(10700, "USD"), Available: true},
+	}
+	if len(expected) != len(fares) {
+		t.Fatalf("Fare slices lengths differ. Want \n%v, \ngot \n%v", expected, fares)
+	}
+	for i, want := range expected {
+		if have := fares[i]; !have.Date.Equal(want.Date) || have.Price != want.Price || have.Available != want.Available {
+			t.Errorf("Fare %d differs. Want %v, got %v", i, want, have)
+		}
+	}
+	// The week view of a single search covers the whole range.
+	if rt.searches != 1 {
+		t.Errorf("Expected 1 search, got %d", rt.searches)
+	}
+}
+
+func TestGetRoundTripsSpirit(t *testing.T) {
+	sc := NewSpiritScraper()
+	sc.transport = newSingularMockRoundTripper("./testScrapingSites/spiritAirlinesRoundTrip.html", "text/html; charset=utf-8")
+	outbound, inbound, err := sc.GetRoundTrips("BOS", "DEN", time.Date(2019, time.Month(9), 13, 0, 0, 0, 0, time.UTC), time.Date(2019, time.Month(9), 16, 0, 0, 0, 0, time.UTC), 1, 0, 0)
+	if err != nil {
+		t.Fatalf("Error while getting the trips: %v", err)
+	}
+	if len(outbound) != 3 {
+		t.Errorf("Expected 3 outbound trips, got %v", outbound)
+	}
+
+	expectedInbound := []*Trip{
+		&Trip{
+			Fares: []*Fare{adultFare("standard", 139.48, true)},
+			Legs: []*Leg{
+				&Leg{Dep: "Denver, CO", Arr: "Baltimore, MD / Washington, DC AREA", DepTime: time.Date(2019, time.Month(9), 16, 6, 10, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 16, 11, 52, 0, 0, time.UTC), Id: "NK116"},
+				&Leg{Dep: "Baltimore, MD / Washington, DC AREA", Arr: "Boston, MA", DepTime: time.Date(2019, time.Month(9), 16, 13, 15, 0, 0, time.UTC), ArrTime: time.Date(2019, time.Month(9), 16, 18, 40, 0, 0, time.UTC), Id: "NK2026"},
+			},
+		},
+	}
+	checkTrips(t, expectedInbound, inbound)
+
+	combined := CombineTrips(outbound[2], inbound[0])
+	expectedFares := []*Fare{adultFare("standard", 171.98+139.48, true)}
+	if len(combined.Fares) != 1 || !combined.Fares[0].Equals(expectedFares[0]) {
+		t.Errorf("Combined fares differ. Want \n%v, \ngot \n%v", expectedFares, combined.Fares)
+	}
+	if len(combined.Legs) != 4 {
+		t.Errorf("Combined legs differ. Want 4 legs, got \n%v", combined.Legs)
+	}
+}
+
+func TestSpiritSearchForm(t *testing.T) {
+	segments := []Segment{
+		{"BOS", "DEN", time.Date(2019, time.Month(9), 13, 0, 0, 0, 0, time.UTC)},
+		{"DEN", "LAS", time.Date(2019, time.Month(9), 16, 0, 0, 0, 0, time.UTC)},
+	}
+	form := spiritSearchForm(spiritMultiCity, segments, 2, 1, 0)
+	expected := map[string]string{
+		"tripType":              "multiCity",
+		"from":                  "BOS",
+		"departDate":            "9/13/2019",
+		"departDateDisplay":     "09/13/2019",
+		"fromMultiCity2":        "DEN",
+		"toMultiCity2":          "LAS",
+		"dateMultiCity2":        "9/16/2019",
+		"dateMultiCityDisplay2": "09/16/2019",
+		"fromMultiCity3":        "",
+		"ADT":                   "2",
+		"CHD":                   "1",
+	}
+	for key, value := range expected {
+		if form.Get(key) != value {
+			t.Errorf("Form field %s differs. Want %q, got %q", key, value, form.Get(key))
+		}
+	}
+}
+
+func TestGetTripsPartySpirit(t *testing.T) {
+	sc := NewSpiritScraper()
+	sc.transport = newSingularMockRoundTripper("./testScrapingSites/spiritAirlines.html", "text/html; charset=utf-8")
+	trips, err := sc.GetTrips("BOS", "DEN", 13, 9, 2019, 2, 1, 1)
+	if err != nil {
+		t.Fatalf("Error while getting the trips: %v", err)
+	}
+	expected := &Fare{
+		Type:          "standard",
+		Price:         money.New(15818, "USD"),
+		Prices:        map[PassengerType]money.Money{Adult: money.New(15818, "USD"), Child: money.New(15818, "USD"), Infant: money.New(0, "USD")},
+		Total:         money.New(47454, "USD"),
+		TaxesIncluded: true,
+	}
+	if !trips[0].Fares[0].Equals(expected) {
+		t.Errorf("Fares differ. Want \n%v, \ngot \n%v", expected, trips[0].Fares[0])
+	}
+}
+
+func TestGetTripsBundlesSpirit(t *testing.T) {
+	sc := NewSpiritScraper()
+	sc.transport = newSingularMockRoundTripper("./testScrapingSites/spiritAirlinesBundles.html", "text/html; charset=utf-8")
+	trips, err := sc.GetTrips("BOS", "DEN", 13, 9, 2019, 1, 0, 0)
+	if err != nil {
+		t.Fatalf("Error while getting the trips: %v", err)
+	}
+	expectedFares := []*Fare{adultFare("standard", 158.18, true), adultFare("bundleIt", 231.17, true)}
+	if len(trips[0].Fares) != len(expectedFares) {
+		t.Fatalf("Fares slices differ. Want \n%v, \ngot \n%v", expectedFares, trips[0].Fares)
+	}
+	for i, ef := range expectedFares {
+		if !trips[0].Fares[i].Equals(ef) {
+			t.Errorf("Fares slices differ. Want \n%v, \ngot \n%v", expectedFares, trips[0].Fares)
+		}
+	}
+
+	testCases := []struct {
+		extras        Extras
+		expectedTrip  int
+		expectedFare  string
+		expectedPrice money.Money
+	}{
+		{Extras{}, 2, "9Dollar", money.New(12208, "USD")},
+		{Extras{CheckedBags: 1}, 2, "9Dollar", money.New(14308, "USD")},
+		{Extras{CarryOns: 1, CheckedBags: 1}, 2, "9Dollar", money.New(16908, "USD")},
+	}
+	fees := SpiritAncillaryFees()
+	fees.ByFareType["9Dollar"] = BagFees{CarryOn: money.New(2600, "USD"), CheckedBags: []money.Money{money.New(2100, "USD")}}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Cheapest with %+v", tc.extras), func(t *testing.T) {
+			trip, fare, price, err := CheapestWith(trips, fees, newPassengers(1, 0, 0), tc.extras)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if trip != trips[tc.expectedTrip] || fare.Type != tc.expectedFare || price != tc.expectedPrice {
+				t.Errorf("Expected %s fare of trip %d for %v, got %v for %v", tc.expectedFare, tc.expectedTrip, tc.expectedPrice, fare, price)
+			}
+		})
+	}
+
+	bundlePrice, err := fees.PriceWith(trips[0].Fares[1], newPassengers(1, 0, 0), Extras{CarryOns: 1, CheckedBags: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The bundle includes a carry-on and a checked bag, so only the second checked bag is paid for.
+	if expected := money.New(23117+4200, "USD"); bundlePrice != expected {
+		t.Errorf("Expected %v, got %v", expected, bundlePrice)
+	}
+}
+
+// spiritSessionRoundTripper serves page for every request, counting the sessions opened and the searches made. The
+// first expired results requests get redirected to the home page, as spirit.com does with expired sessions.
+type spiritSessionRoundTripper struct {
+	page     http.RoundTripper
+	mu       sync.Mutex
+	opened   int
+	searches int
+	expired  int
+	down     bool
+	// delay is how long opening a session takes to fail when down.
+	delay time.Duration
+}
+
+func (rt *spiritSessionRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	switch {
+	case r.URL.Path == "/Default.aspx" && r.URL.RawQuery == "":
+		if rt.down {
+			time.Sleep(rt.delay)
+			return nil, fmt.Errorf("connection refused")
+		}
+		rt.opened++
+	case r.URL.Path == "/Default.aspx" && r.URL.RawQuery == "action=search":
+		rt.searches++
+	case r.URL.Path == "/DPPCalendarMarket.aspx" && rt.expired > 0:
+		rt.expired--
+		return &http.Response{
+			StatusCode: http.StatusFound,
+			Header:     http.Header{"Location": {"/Default.aspx?sessionExpired=true"}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    r,
+		}, nil
+	}
+	return rt.page.RoundTrip(r)
+}
+
+func TestSpiritSessions(t *testing.T) {
+	rt := &spiritSessionRoundTripper{page: newSingularMockRoundTripper("./testScrapingSites/spiritAirlines.html", "text/html; charset=utf-8")}
+	sc := NewSpiritScraperPool(3)
+	sc.transport = rt
+
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			trips, err := sc.GetTrips("BOS", "DEN", 13, 9, 2019, 1, 0, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(trips) != 3 || trips[2].Legs[1].Id != "NK355" {
+				t.Errorf("Unexpected trips %v", trips)
+			}
+		}()
+	}
+	wg.Wait()
+	if rt.opened > 3 {
+		t.Errorf("Expected at most 3 sessions, got %d", rt.opened)
+	}
+
+	// An expired session gets renewed and the search made again.
+	opened := rt.opened
+	rt.expired = 1
+	trips, err := sc.GetTrips("BOS", "DEN", 13, 9, 2019, 1, 0, 0)
+	if err != nil || len(trips) != 3 {
+		t.Errorf("Expected the search to succeed after renewing the session, got %v, %v", trips, err)
+	}
+	if rt.opened != opened+1 {
+		t.Errorf("Expected a session to be renewed, got %d new ones", rt.opened-opened)
+	}
+
+	// Sessions too old are renewed before searching.
+	sc.now = func() time.Time { return time.Now().Add(spiritSessionAge * 2) }
+	opened = rt.opened
+	_, err = sc.GetTrips("BOS", "DEN", 13, 9, 2019, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.opened != opened+1 {
+		t.Errorf("Expected an old session to be renewed, got %d new ones", rt.opened-opened)
+	}
+}
+
+func TestSpiritSessionError(t *testing.T) {
+	sc := NewSpiritScraperPool(1)
+	sc.transport = &spiritSessionRoundTripper{down: true}
+	if _, err := sc.GetTrips("BOS", "DEN", 13, 9, 2019, 1, 0, 0); err == nil {
+		t.Error("Expected an error opening the session")
+	}
+	// The failed session doesn't take the place of a working one.
+	sc.transport = &spiritSessionRoundTripper{page: newSingularMockRoundTripper("./testScrapingSites/spiritAirlines.html", "text/html; charset=utf-8")}
+	if _, err := sc.GetTrips("BOS", "DEN", 13, 9, 2019, 1, 0, 0); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSpiritSessionErrorWaiting(t *testing.T) {
+	sc := NewSpiritScraperPool(1)
+	sc.transport = &spiritSessionRoundTripper{down: true, delay: time.Millisecond * 50}
+	// The second search waits for the session the first fails to open, then fails to open its own.
+	errs := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := sc.GetTrips("BOS", "DEN", 13, 9, 2019, 1, 0, 0)
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err == nil {
+				t.Error("Expected an error opening the session")
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatal("Search still waiting for a session after the other failed to open it")
+		}
+	}
+}
@@ -3,11 +3,17 @@ package scraping
 import (
 	"fmt"
 	"time"
+
+	"github.com/jcasado94/connecc/money"
 )
 
 type Trip struct {
 	Fares []*Fare
 	Legs  []*Leg
+	// Provider is the catalogue id of the provider the trip was found with, and ScrapedAt when. Both are set by the
+	// scrapers created with NewProviderScraper.
+	Provider  int
+	ScrapedAt time.Time
 }
 
 func newTrip(fares []*Fare, legs []*Leg) *Trip {
@@ -17,6 +23,28 @@ func newTrip(fares []*Fare, legs []*Leg) *Trip {
 	}
 }
 
+// copy returns a deep copy of t, so that changes to either don't show in the other.
+func (t *Trip) copy() *Trip {
+	c := *t
+	c.Legs = make([]*Leg, 0, len(t.Legs))
+	for _, l := range t.Legs {
+		leg := *l
+		c.Legs = append(c.Legs, &leg)
+	}
+	c.Fares = make([]*Fare, 0, len(t.Fares))
+	for _, f := range t.Fares {
+		fare := *f
+		if f.Prices != nil {
+			fare.Prices = make(map[PassengerType]money.Money, len(f.Prices))
+			for pt, p := range f.Prices {
+				fare.Prices[pt] = p
+			}
+		}
+		c.Fares = append(c.Fares, &fare)
+	}
+	return &c
+}
+
 func (t *Trip) String() string {
 	return fmt.Sprintf("Trip{Price:%v, Legs:%v}", t.Fares, t.Legs)
 }
@@ -46,18 +74,173 @@ func newLeg(dep, arr, id string, depTime, arrTime time.Time) *Leg {
 	}
 }
 
+type PassengerType string
+
+const (
+	Adult      PassengerType = "adult"
+	Child      PassengerType = "child"
+	Infant     PassengerType = "infant"
+	Concession PassengerType = "concession"
+)
+
+// Passengers holds how many travellers of each type make up the party of a search.
+type Passengers map[PassengerType]int
+
+func newPassengers(adults, children, infants int) Passengers {
+	passengers := make(Passengers)
+	for pt, n := range map[PassengerType]int{Adult: adults, Child: children, Infant: infants} {
+		if n > 0 {
+			passengers[pt] = n
+		}
+	}
+	return passengers
+}
+
+func (p Passengers) count() int {
+	n := 0
+	for _, c := range p {
+		n += c
+	}
+	return n
+}
+
 type Fare struct {
-	Type  string
-	Price float64
+	Type string
+	// Price is the price for a single adult.
+	Price money.Money
+	// Prices holds the price for a single traveller of each passenger type in the party.
+	Prices map[PassengerType]money.Money
+	// Total is the price for the whole party.
+	Total         money.Money
+	TaxesIncluded bool
 }
 
-func newFare(t string, p float64) *Fare {
+func newFare(t string, p money.Money) *Fare {
 	return &Fare{
 		Type:  t,
 		Price: p,
 	}
 }
 
+// newPartyFare builds the fare of a party given the price a single traveller of each of its passenger types pays.
+// All prices must be in the same currency.
+func newPartyFare(t string, prices map[PassengerType]money.Money, passengers Passengers, taxesIncluded bool) *Fare {
+	fare := &Fare{
+		Type:          t,
+		Price:         prices[Adult],
+		Prices:        make(map[PassengerType]money.Money),
+		Total:         money.New(0, prices[Adult].Currency),
+		TaxesIncluded: taxesIncluded,
+	}
+	for pt, n := range passengers {
+		fare.Prices[pt] = prices[pt]
+		fare.Total.Amount += prices[pt].Times(n).Amount
+	}
+	return fare
+}
+
+// newEvenPartyFare builds the fare of a party from the price the whole party pays, for providers that charge every
+// passenger type alike.
+func newEvenPartyFare(t string, total money.Money, passengers Passengers, taxesIncluded bool) *Fare {
+	perPassenger := total
+	if n := passengers.count(); n > 1 {
+		perPassenger = money.New(total.Amount/int64(n), total.Currency)
+	}
+	prices := map[PassengerType]money.Money{Adult: perPassenger, Child: perPassenger, Infant: perPassenger, Concession: perPassenger}
+	fare := newPartyFare(t, prices, passengers, taxesIncluded)
+	fare.Total = total
+	return fare
+}
+
+func (f *Fare) Equals(f2 *Fare) bool {
+	if f.Type != f2.Type || f.Price != f2.Price || f.Total != f2.Total || f.TaxesIncluded != f2.TaxesIncluded || len(f.Prices) != len(f2.Prices) {
+		return false
+	}
+	for pt, p := range f.Prices {
+		if p2, ok := f2.Prices[pt]; !ok || p != p2 {
+			return false
+		}
+	}
+	return true
+}
+
 func (f *Fare) String() string {
-	return fmt.Sprintf("%s: %f", f.Type, f.Price)
+	return fmt.Sprintf("%s: %v (total %v)", f.Type, f.Price, f.Total)
+}
+
+// plus returns the fare of booking both f and f2, which must be of the same type and currency.
+func (f *Fare) plus(f2 *Fare) (*Fare, error) {
+	price, err := f.Price.Add(f2.Price)
+	if err != nil {
+		return nil, err
+	}
+	total, err := f.Total.Add(f2.Total)
+	if err != nil {
+		return nil, err
+	}
+	sum := &Fare{
+		Type:          f.Type,
+		Price:         price,
+		Prices:        make(map[PassengerType]money.Money),
+		Total:         total,
+		TaxesIncluded: f.TaxesIncluded && f2.TaxesIncluded,
+	}
+	for pt, p := range f.Prices {
+		sum.Prices[pt] = p
+	}
+	for pt, p := range f2.Prices {
+		if p1, ok := sum.Prices[pt]; ok {
+			p, err = p1.Add(p)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sum.Prices[pt] = p
+	}
+	return sum, nil
+}
+
+// CombineTrips joins trips booked together, such as the outbound and inbound options of a round trip, into a single trip.
+// The combined trip only holds the fare types offered by every one of them in the same currency, priced as their sum.
+func CombineTrips(trips ...*Trip) *Trip {
+	combined := newTrip(make([]*Fare, 0), make([]*Leg, 0))
+	if len(trips) == 0 {
+		return combined
+	}
+	for _, f := range trips[0].Fares {
+		fare, offered := f, true
+		for _, t := range trips[1:] {
+			f2 := t.getFare(f.Type)
+			if f2 == nil {
+				offered = false
+				break
+			}
+			var err error
+			fare, err = fare.plus(f2)
+			if err != nil {
+				offered = false
+				break
+			}
+		}
+		if offered {
+			combined.Fares = append(combined.Fares, fare)
+		}
+	}
+	combined.Provider, combined.ScrapedAt = trips[0].Provider, trips[0].ScrapedAt
+	for _, t := range trips {
+		combined.Legs = append(combined.Legs, t.Legs...)
+		if t.ScrapedAt.Before(combined.ScrapedAt) {
+			combined.ScrapedAt = t.ScrapedAt
+		}
+	}
+	return combined
+}
+
+func (t *Trip) getFare(fareType string) *Fare {
+	for _, f := range t.Fares {
+		if f.Type == fareType {
+			return f
+		}
+	}
+	return nil
 }
@@ -15,6 +15,17 @@ func processDayDifference(baseTime *time.Time, hour, min int) time.Time {
 	return fixedTime
 }
 
+// daysBetween returns the dates, at midnight UTC, of every day between from and to, both included.
+func daysBetween(from, to time.Time) []time.Time {
+	days := make([]time.Time, 0)
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
 type singularMockRoundTripper struct {
 	mockUrl     string
 	contentType string
@@ -44,35 +55,19 @@ func (rt *singularMockRoundTripper) RoundTrip(r *http.Request) (*http.Response,
 	return response, nil
 }
 
-type multipleMockRoundTripper struct {
-	urlToFilePath    map[string]string
-	urlToContentType map[string]string
+type latencyRoundTripper struct {
+	rt      http.RoundTripper
+	latency time.Duration
 }
 
-func newMultipleMockRoundTripper(urlToFilePath, urlToContentType map[string]string) *multipleMockRoundTripper {
-	return &multipleMockRoundTripper{
-		urlToFilePath:    urlToFilePath,
-		urlToContentType: urlToContentType,
+func newLatencyRoundTripper(rt http.RoundTripper, latency time.Duration) *latencyRoundTripper {
+	return &latencyRoundTripper{
+		rt:      rt,
+		latency: latency,
 	}
 }
 
-func (rt *multipleMockRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
-	response := &http.Response{
-		Header:     make(http.Header),
-		Request:    r,
-		StatusCode: http.StatusOK,
-	}
-	url := r.URL.String()
-	path := rt.urlToFilePath[url]
-	contentType := rt.urlToContentType[url]
-
-	response.Header.Set("Content-type", contentType)
-
-	dat, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	response.Body = ioutil.NopCloser(bytes.NewReader(dat))
-
-	return response, nil
+func (rt *latencyRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	time.Sleep(rt.latency)
+	return rt.rt.RoundTrip(r)
 }
@@ -0,0 +1,129 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// sketchAccuracy is the relative error of the quantiles returned by a Sketch.
+	sketchAccuracy = 0.01
+	// maxSketchBins bounds the size of a Sketch. Beyond it, the lowest bins are collapsed, losing accuracy on the
+	// lowest quantiles only.
+	maxSketchBins = 512
+)
+
+var (
+	sketchGamma    = (1 + sketchAccuracy) / (1 - sketchAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// Sketch estimates the quantiles of a stream of non-negative values, such as prices, within sketchAccuracy of their
+// value. It counts the values falling into bins growing exponentially, as a DDSketch, so that two sketches are merged
+// by adding up their bins.
+type Sketch struct {
+	// Zeros counts the values of zero or less, which are not binned.
+	Zeros int   `json:"zeros"`
+	Bins  []Bin `json:"bins"`
+}
+
+// Bin counts the values between sketchGamma^(Index-1) and sketchGamma^Index.
+type Bin struct {
+	Index int `json:"index"`
+	Count int `json:"count"`
+}
+
+func sketchIndex(x float64) int {
+	return int(math.Ceil(math.Log(x) / sketchLogGamma))
+}
+
+// sketchValue returns the value of a bin, the one with the lowest relative error to any value within it.
+func sketchValue(index int) float64 {
+	return 2 * math.Pow(sketchGamma, float64(index)) / (sketchGamma + 1)
+}
+
+func (s *Sketch) Add(x float64) {
+	if x <= 0 {
+		s.Zeros++
+		return
+	}
+	s.addBin(Bin{sketchIndex(x), 1})
+	s.collapse()
+}
+
+// addBin adds b to the bin of its index, keeping the bins sorted by index.
+func (s *Sketch) addBin(b Bin) {
+	i := sort.Search(len(s.Bins), func(i int) bool { return s.Bins[i].Index >= b.Index })
+	if i < len(s.Bins) && s.Bins[i].Index == b.Index {
+		s.Bins[i].Count += b.Count
+		return
+	}
+	s.Bins = append(s.Bins, Bin{})
+	copy(s.Bins[i+1:], s.Bins[i:])
+	s.Bins[i] = b
+}
+
+func (s *Sketch) collapse() {
+	if len(s.Bins) <= maxSketchBins {
+		return
+	}
+	extra := len(s.Bins) - maxSketchBins
+	for _, b := range s.Bins[:extra] {
+		s.Bins[extra].Count += b.Count
+	}
+	s.Bins = append(s.Bins[:0], s.Bins[extra:]...)
+}
+
+// Merge adds the values counted by s2 to s.
+func (s *Sketch) Merge(s2 Sketch) {
+	s.Zeros += s2.Zeros
+	for _, b := range s2.Bins {
+		s.addBin(b)
+	}
+	s.collapse()
+}
+
+func (s *Sketch) Count() int {
+	n := s.Zeros
+	for _, b := range s.Bins {
+		n += b.Count
+	}
+	return n
+}
+
+// Quantile returns an estimate of the q-quantile of the values, q being between 0 and 1, or 0 if there are none.
+func (s *Sketch) Quantile(q float64) float64 {
+	n := s.Count()
+	if n == 0 {
+		return 0
+	}
+	rank := int(math.Floor(math.Max(0, math.Min(1, q)) * float64(n-1)))
+	seen := s.Zeros
+	if rank < seen {
+		return 0
+	}
+	for _, b := range s.Bins {
+		seen += b.Count
+		if rank < seen {
+			return sketchValue(b.Index)
+		}
+	}
+	return sketchValue(s.Bins[len(s.Bins)-1].Index)
+}
+
+// Rank returns an estimate of the fraction of the values below x, as 0.1 for a price cheaper than 90% of the others.
+func (s *Sketch) Rank(x float64) float64 {
+	n := s.Count()
+	if n == 0 || x <= 0 {
+		return 0
+	}
+	below := s.Zeros
+	index := sketchIndex(x)
+	for _, b := range s.Bins {
+		if b.Index >= index {
+			break
+		}
+		below += b.Count
+	}
+	return float64(below) / float64(n)
+}
@@ -0,0 +1,96 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	values := []float64{120, 95.5, 210, 80, 150, 99.99}
+	var s, left, right Summary
+	for i, x := range values {
+		s.Add(x)
+		if i < 2 {
+			left.Add(x)
+		} else {
+			right.Add(x)
+		}
+	}
+	left.Merge(right)
+
+	mean, sq := 0.0, 0.0
+	for _, x := range values {
+		mean += x
+	}
+	mean /= float64(len(values))
+	for _, x := range values {
+		sq += (x - mean) * (x - mean)
+	}
+	variance := sq / float64(len(values)-1)
+
+	for _, got := range []Summary{s, left} {
+		if got.N != len(values) || got.Min != 80 || got.Max != 210 {
+			t.Errorf("Expected 6 values from 80 to 210, got %+v", got)
+		}
+		if math.Abs(got.Mean-mean) > 1e-9 || math.Abs(got.Variance()-variance) > 1e-9 {
+			t.Errorf("Expected mean %v and variance %v, got %v and %v", mean, variance, got.Mean, got.Variance())
+		}
+	}
+	if (Summary{N: 1, Mean: 3}).Variance() != 0 {
+		t.Errorf("Expected no variance for a single value")
+	}
+}
+
+func TestSketch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	values := make([]float64, 0)
+	var s, left, right Sketch
+	for i := 0; i < 10000; i++ {
+		x := 20 + r.ExpFloat64()*100
+		values = append(values, x)
+		s.Add(x)
+		if i%3 == 0 {
+			left.Add(x)
+		} else {
+			right.Add(x)
+		}
+	}
+	left.Merge(right)
+	sort.Float64s(values)
+
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 0.99, 1} {
+		expected := values[int(q*float64(len(values)-1))]
+		for _, got := range []float64{s.Quantile(q), left.Quantile(q)} {
+			if math.Abs(got-expected)/expected > sketchAccuracy {
+				t.Errorf("Expected quantile %v to be about %v, got %v", q, expected, got)
+			}
+		}
+	}
+	if rank := s.Rank(values[len(values)/4]); math.Abs(rank-0.25) > 0.01 {
+		t.Errorf("Expected rank 0.25, got %v", rank)
+	}
+	if n := left.Count(); n != len(values) {
+		t.Errorf("Expected %d values, got %d", len(values), n)
+	}
+
+	var empty Sketch
+	empty.Add(0)
+	if empty.Quantile(0.5) != 0 || empty.Rank(10) != 1 {
+		t.Errorf("Expected zeros to be counted apart")
+	}
+}
+
+func TestSketchCollapses(t *testing.T) {
+	var s Sketch
+	for x := 1.0; x < 1e12; x *= 1.05 {
+		s.Add(x)
+	}
+	if len(s.Bins) > maxSketchBins {
+		t.Errorf("Expected at most %d bins, got %d", maxSketchBins, len(s.Bins))
+	}
+	if max := s.Quantile(1); math.Abs(max-1e12)/1e12 > 0.06 {
+		t.Errorf("Expected the highest values to stay accurate, got %v", max)
+	}
+}
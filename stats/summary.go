@@ -0,0 +1,58 @@
+// Package stats keeps streaming statistics of prices, which can be updated one price at a time and merged, so that
+// they can be stored along with the averages and never need the prices they were built from.
+package stats
+
+import "math"
+
+// Summary holds the count, mean, extremes and variance of a stream of values, computed with Welford's algorithm.
+type Summary struct {
+	N    int     `json:"n"`
+	Mean float64 `json:"mean"`
+	// M2 is the sum of the squared differences from the mean.
+	M2  float64 `json:"m2"`
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+func (s *Summary) Add(x float64) {
+	if s.N == 0 || x < s.Min {
+		s.Min = x
+	}
+	if s.N == 0 || x > s.Max {
+		s.Max = x
+	}
+	s.N++
+	delta := x - s.Mean
+	s.Mean += delta / float64(s.N)
+	s.M2 += delta * (x - s.Mean)
+}
+
+// Merge adds the values summed up by s2 to s.
+func (s *Summary) Merge(s2 Summary) {
+	switch {
+	case s2.N == 0:
+		return
+	case s.N == 0:
+		*s = s2
+		return
+	}
+	n := s.N + s2.N
+	delta := s2.Mean - s.Mean
+	s.M2 += s2.M2 + delta*delta*float64(s.N)*float64(s2.N)/float64(n)
+	s.Mean += delta * float64(s2.N) / float64(n)
+	s.Min = math.Min(s.Min, s2.Min)
+	s.Max = math.Max(s.Max, s2.Max)
+	s.N = n
+}
+
+// Variance returns the sample variance of the values, 0 with less than two of them.
+func (s Summary) Variance() float64 {
+	if s.N < 2 {
+		return 0
+	}
+	return s.M2 / float64(s.N-1)
+}
+
+func (s Summary) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
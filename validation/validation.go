@@ -0,0 +1,197 @@
+// Package validation checks the trips found by scrapers before they are ingested, reporting every problem found as a
+// Violation, and normalises them so that the same trip always looks the same whichever scrape it comes from.
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jcasado94/connecc/providers"
+	"github.com/jcasado94/connecc/scraping"
+)
+
+const defaultMaxLayover = time.Hour * 24
+
+type Code string
+
+const (
+	NoLegs                 Code = "noLegs"
+	EmptyStop              Code = "emptyStop"
+	UnknownStop            Code = "unknownStop"
+	ArrivalBeforeDeparture Code = "arrivalBeforeDeparture"
+	LegsNotChained         Code = "legsNotChained"
+	NegativeLayover        Code = "negativeLayover"
+	LayoverTooLong         Code = "layoverTooLong"
+	NoFares                Code = "noFares"
+	InvalidFare            Code = "invalidFare"
+	UnknownFareType        Code = "unknownFareType"
+)
+
+// Violation is a problem found in a trip. Leg and Fare are the indexes of the leg or fare at fault, or -1.
+type Violation struct {
+	Code    Code
+	Leg     int
+	Fare    int
+	Message string
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("%s: %s", v.Code, v.Message)
+}
+
+func legViolation(code Code, leg int, format string, a ...interface{}) Violation {
+	return Violation{Code: code, Leg: leg, Fare: -1, Message: fmt.Sprintf(format, a...)}
+}
+
+func fareViolation(code Code, fare int, format string, a ...interface{}) Violation {
+	return Violation{Code: code, Leg: -1, Fare: fare, Message: fmt.Sprintf(format, a...)}
+}
+
+type Validator struct {
+	// KnownStop tells whether a stop id is known. Stops aren't checked if nil.
+	KnownStop func(id string) bool
+	// MaxLayover is the longest wait allowed between two legs. defaultMaxLayover is used if 0.
+	MaxLayover time.Duration
+	// Catalogue checks the fare types against the ones of the trip's provider. Fare types aren't checked if nil.
+	Catalogue *providers.Catalogue
+}
+
+// Validate returns the violations found in t, none if it's valid.
+func (v *Validator) Validate(t *scraping.Trip) []Violation {
+	violations := make([]Violation, 0)
+	violations = append(violations, v.validateLegs(t.Legs)...)
+	violations = append(violations, v.validateFares(t)...)
+	return violations
+}
+
+func (v *Validator) validateLegs(legs []*scraping.Leg) []Violation {
+	violations := make([]Violation, 0)
+	if len(legs) == 0 {
+		return append(violations, legViolation(NoLegs, -1, "Trip has no legs"))
+	}
+	maxLayover := v.MaxLayover
+	if maxLayover == 0 {
+		maxLayover = defaultMaxLayover
+	}
+	for i, l := range legs {
+		for _, stop := range []string{l.Dep, l.Arr} {
+			if stop == "" {
+				violations = append(violations, legViolation(EmptyStop, i, "Leg %d has an empty stop", i))
+			} else if v.KnownStop != nil && !v.KnownStop(stop) {
+				violations = append(violations, legViolation(UnknownStop, i, "Leg %d stops at unknown %q", i, stop))
+			}
+		}
+		if l.ArrTime.Before(l.DepTime) {
+			violations = append(violations, legViolation(ArrivalBeforeDeparture, i, "Leg %d arrives at %v, before departing at %v", i, l.ArrTime, l.DepTime))
+		}
+		if i == 0 {
+			continue
+		}
+		prev := legs[i-1]
+		if prev.Arr != l.Dep {
+			violations = append(violations, legViolation(LegsNotChained, i, "Leg %d departs from %q but leg %d arrives at %q", i, l.Dep, i-1, prev.Arr))
+		}
+		layover := l.DepTime.Sub(prev.ArrTime)
+		if layover < 0 {
+			violations = append(violations, legViolation(NegativeLayover, i, "Leg %d departs %v before leg %d arrives", i, -layover, i-1))
+		} else if layover > maxLayover {
+			violations = append(violations, legViolation(LayoverTooLong, i, "Leg %d departs %v after leg %d arrives", i, layover, i-1))
+		}
+	}
+	return violations
+}
+
+func (v *Validator) validateFares(t *scraping.Trip) []Violation {
+	violations := make([]Violation, 0)
+	if len(t.Fares) == 0 {
+		return append(violations, fareViolation(NoFares, -1, "Trip has no fares"))
+	}
+	var provider *providers.Provider
+	if v.Catalogue != nil {
+		provider, _ = v.Catalogue.ById(t.Provider)
+	}
+	seen := make(map[string]bool)
+	for i, f := range t.Fares {
+		switch {
+		case f == nil:
+			violations = append(violations, fareViolation(InvalidFare, i, "Fare %d is missing", i))
+			continue
+		case f.Type == "" || seen[f.Type]:
+			violations = append(violations, fareViolation(InvalidFare, i, "Fare %d has an empty or repeated type %q", i, f.Type))
+		case provider != nil && !provider.HasFareType(f.Type):
+			violations = append(violations, fareViolation(UnknownFareType, i, "Fare %d has type %q, unknown to %s", i, f.Type, provider.Name))
+		}
+		seen[f.Type] = true
+		if f.Price.Amount <= 0 || f.Total.Amount < f.Price.Amount {
+			violations = append(violations, fareViolation(InvalidFare, i, "Fare %d costs %v, %v in total", i, f.Price, f.Total))
+		}
+		if f.Price.Currency == "" || f.Total.Currency != f.Price.Currency {
+			violations = append(violations, fareViolation(InvalidFare, i, "Fare %d mixes currencies %q and %q", i, f.Price.Currency, f.Total.Currency))
+		}
+		for pt, p := range f.Prices {
+			if p.Amount < 0 || p.Currency != f.Price.Currency {
+				violations = append(violations, fareViolation(InvalidFare, i, "Fare %d costs %v for %s", i, p, pt))
+			}
+		}
+	}
+	return violations
+}
+
+// Rejection is a trip that didn't pass validation, along with the reasons why.
+type Rejection struct {
+	Trip       *scraping.Trip
+	Violations []Violation
+}
+
+// Check normalises trips and splits them into the valid ones and the rejected ones.
+func (v *Validator) Check(trips []*scraping.Trip) ([]*scraping.Trip, []Rejection) {
+	valid := make([]*scraping.Trip, 0, len(trips))
+	rejected := make([]Rejection, 0)
+	for _, t := range trips {
+		t = Normalize(t)
+		if violations := v.Validate(t); len(violations) > 0 {
+			rejected = append(rejected, Rejection{t, violations})
+		} else {
+			valid = append(valid, t)
+		}
+	}
+	return valid, rejected
+}
+
+// Normalize returns a copy of t with the blanks around and within stop names and leg ids collapsed, the missing fares
+// dropped and the fares sorted from the cheapest for the whole party.
+func Normalize(t *scraping.Trip) *scraping.Trip {
+	n := &scraping.Trip{
+		Legs:      make([]*scraping.Leg, 0, len(t.Legs)),
+		Fares:     make([]*scraping.Fare, 0, len(t.Fares)),
+		Provider:  t.Provider,
+		ScrapedAt: t.ScrapedAt,
+	}
+	for _, l := range t.Legs {
+		if l == nil {
+			continue
+		}
+		nl := *l
+		nl.Dep, nl.Arr, nl.Id = normalizeName(l.Dep), normalizeName(l.Arr), normalizeName(l.Id)
+		n.Legs = append(n.Legs, &nl)
+	}
+	for _, f := range t.Fares {
+		if f != nil {
+			n.Fares = append(n.Fares, f)
+		}
+	}
+	sort.SliceStable(n.Fares, func(i, j int) bool {
+		fi, fj := n.Fares[i], n.Fares[j]
+		if cmp, err := fi.Total.Cmp(fj.Total); err == nil && cmp != 0 {
+			return cmp < 0
+		}
+		return fi.Type < fj.Type
+	})
+	return n
+}
+
+func normalizeName(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
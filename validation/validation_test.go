@@ -0,0 +1,128 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jcasado94/connecc/money"
+	"github.com/jcasado94/connecc/providers"
+	"github.com/jcasado94/connecc/scraping"
+)
+
+func at(hour, min int) time.Time {
+	return time.Date(2019, time.Month(9), 8, hour, min, 0, 0, time.UTC)
+}
+
+func usd(amount int64) money.Money {
+	return money.New(amount, "USD")
+}
+
+// validTrip flies BOS --> FLL --> DEN with two fares.
+func validTrip() *scraping.Trip {
+	return &scraping.Trip{
+		Legs: []*scraping.Leg{
+			{Dep: "BOS", Arr: "FLL", Id: "NK123", DepTime: at(7, 0), ArrTime: at(10, 20)},
+			{Dep: "FLL", Arr: "DEN", Id: "NK456", DepTime: at(12, 5), ArrTime: at(14, 40)},
+		},
+		Fares: []*scraping.Fare{
+			{Type: "standard", Price: usd(12000), Total: usd(12000), Prices: map[scraping.PassengerType]money.Money{scraping.Adult: usd(12000)}},
+			{Type: "member", Price: usd(9000), Total: usd(9000)},
+		},
+		Provider: 0,
+	}
+}
+
+func codes(violations []Violation) []Code {
+	cs := make([]Code, 0, len(violations))
+	for _, v := range violations {
+		cs = append(cs, v.Code)
+	}
+	return cs
+}
+
+func TestValidate(t *testing.T) {
+	catalogue, err := providers.Parse([]byte(`{
+		"spirit": {"id": 0, "mode": "air", "currency": "USD", "fareTypes": ["standard", "member"]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := map[string]bool{"BOS": true, "FLL": true, "DEN": true}
+	v := &Validator{
+		KnownStop: func(id string) bool { return known[id] },
+		Catalogue: catalogue,
+	}
+
+	tests := []struct {
+		name     string
+		change   func(t *scraping.Trip)
+		expected []Code
+	}{
+		{"valid", func(t *scraping.Trip) {}, []Code{}},
+		{"no legs", func(t *scraping.Trip) { t.Legs = nil }, []Code{NoLegs}},
+		{"not chained", func(t *scraping.Trip) { t.Legs[1].Dep = "MIA" }, []Code{UnknownStop, LegsNotChained}},
+		{"empty stop", func(t *scraping.Trip) { t.Legs[0].Dep = "" }, []Code{EmptyStop}},
+		{"arrival before departure", func(t *scraping.Trip) { t.Legs[1].ArrTime = at(11, 0) }, []Code{ArrivalBeforeDeparture}},
+		{"negative layover", func(t *scraping.Trip) { t.Legs[1].DepTime = at(9, 0) }, []Code{NegativeLayover}},
+		{"long layover", func(t *scraping.Trip) {
+			t.Legs[1].DepTime, t.Legs[1].ArrTime = at(12, 5).AddDate(0, 0, 2), at(14, 40).AddDate(0, 0, 2)
+		}, []Code{LayoverTooLong}},
+		{"no fares", func(t *scraping.Trip) { t.Fares = nil }, []Code{NoFares}},
+		{"missing fare", func(t *scraping.Trip) { t.Fares[1] = nil }, []Code{InvalidFare}},
+		{"free fare", func(t *scraping.Trip) { t.Fares[1].Price, t.Fares[1].Total = usd(0), usd(0) }, []Code{InvalidFare}},
+		{"total under price", func(t *scraping.Trip) { t.Fares[1].Total = usd(100) }, []Code{InvalidFare}},
+		{"mixed currencies", func(t *scraping.Trip) { t.Fares[1].Total = money.New(9000, "CAD") }, []Code{InvalidFare}},
+		{"negative passenger price", func(t *scraping.Trip) {
+			t.Fares[0].Prices[scraping.Child] = usd(-1)
+		}, []Code{InvalidFare}},
+		{"repeated fare type", func(t *scraping.Trip) { t.Fares[1].Type = "standard" }, []Code{InvalidFare}},
+		{"unknown fare type", func(t *scraping.Trip) { t.Fares[1].Type = "bundle" }, []Code{UnknownFareType}},
+	}
+	for _, test := range tests {
+		trip := validTrip()
+		test.change(trip)
+		if got := codes(v.Validate(trip)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+
+	violations := v.Validate(&scraping.Trip{Legs: validTrip().Legs[1:], Fares: []*scraping.Fare{nil}})
+	if len(violations) != 1 || violations[0].Fare != 0 || violations[0].Leg != -1 {
+		t.Errorf("Expected a violation of fare 0, got %v", violations)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	trip := validTrip()
+	trip.Legs[0].Dep, trip.Legs[0].Id = "  BOS ", "NK  123"
+	trip.Legs = append(trip.Legs, nil)
+	trip.Fares = append(trip.Fares, nil, &scraping.Fare{Type: "bundle", Price: usd(9000), Total: usd(9000)})
+
+	n := Normalize(trip)
+	if n.Legs[0].Dep != "BOS" || n.Legs[0].Id != "NK 123" || len(n.Legs) != 2 {
+		t.Errorf("Legs weren't normalised: %+v", n.Legs)
+	}
+	if trip.Legs[0].Dep != "  BOS " {
+		t.Errorf("The original trip was modified")
+	}
+	types := make([]string, 0)
+	for _, f := range n.Fares {
+		types = append(types, f.Type)
+	}
+	if expected := []string{"bundle", "member", "standard"}; !reflect.DeepEqual(types, expected) {
+		t.Errorf("Expected fares %v, got %v", expected, types)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	invalid := validTrip()
+	invalid.Fares = nil
+	valid, rejected := (&Validator{}).Check([]*scraping.Trip{validTrip(), invalid})
+	if len(valid) != 1 || len(rejected) != 1 {
+		t.Fatalf("Expected a valid and a rejected trip, got %d and %d", len(valid), len(rejected))
+	}
+	if codes(rejected[0].Violations)[0] != NoFares {
+		t.Errorf("Expected %s, got %v", NoFares, rejected[0].Violations)
+	}
+}